@@ -0,0 +1,383 @@
+package main
+
+import (
+	"GoProject/internal/data"
+	"GoProject/internal/jsonpatch"
+	"GoProject/internal/validator"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// createChangeRequestHandler lets any activated user suggest an edit to a
+// helmet, as a JSON Merge Patch of its fields. The patch has to result in a
+// valid helmet that differs from the current one.
+func (app *application) createChangeRequestHandler(w http.ResponseWriter, r *http.Request) {
+	helmet, ok := app.readLiveHelmet(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Patch   json.RawMessage `json:"patch"`
+		Comment string          `json:"comment"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	request := &data.HelmetChangeRequest{
+		HelmetID:    helmet.ID,
+		UserID:      app.contextGetUser(r).ID,
+		BaseVersion: helmet.Version,
+		Patch:       input.Patch,
+		Comment:     strings.TrimSpace(input.Comment),
+	}
+
+	v := validator.New()
+
+	if data.ValidateChangeRequest(v, request); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if !app.applyChangeRequest(w, r, helmet, request) {
+		return
+	}
+
+	if v.Check(len(request.Changes) > 0, "patch", "must change at least one field"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.ChangeRequests.Insert(request)
+	if err != nil {
+		app.saveErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", app.location(r, "/mhelmets/%d/change-requests/%d", helmet.ID, request.ID))
+
+	err = app.writeResponse(w, r, http.StatusCreated, envelope{"change_request": request}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listChangeRequestsHandler is the review queue. It lists pending change
+// requests unless another status is asked for, each with its changes to the
+// helmet as it is now.
+func (app *application) listChangeRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.ChangeRequestFilter
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.HelmetID = int64(app.readInt(qs, "helmet_id", 0, v))
+	input.Status = app.readString(qs, "status", data.ChangeRequestPending)
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafelist = []string{"id", "-id"}
+
+	data.ValidateChangeRequestFilter(v, input.ChangeRequestFilter)
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	app.listChangeRequests(w, r, input.ChangeRequestFilter, input.Filters)
+}
+
+// listOwnChangeRequestsHandler lists the change requests the user has made,
+// so that they can follow up on them.
+func (app *application) listOwnChangeRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.ChangeRequestFilter
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.UserID = app.contextGetUser(r).ID
+	input.Status = app.readString(qs, "status", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-id")
+	input.Filters.SortSafelist = []string{"id", "-id"}
+
+	data.ValidateChangeRequestFilter(v, input.ChangeRequestFilter)
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	app.listChangeRequests(w, r, input.ChangeRequestFilter, input.Filters)
+}
+
+func (app *application) listChangeRequests(w http.ResponseWriter, r *http.Request, filter data.ChangeRequestFilter, filters data.Filters) {
+	requests, metadata, err := app.models.ChangeRequests.GetAll(filter, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.setChangeRequestChanges(requests...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"change_requests": requests, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showChangeRequestHandler(w http.ResponseWriter, r *http.Request) {
+	_, request, ok := app.readChangeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// Besides reviewers, only the user who made a change request can see it.
+	if request.UserID != app.contextGetUser(r).ID {
+		permitted, err := app.userHasPermission(r, "mhelmets:write")
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !permitted {
+			app.notFoundResponse(w, r)
+			return
+		}
+	}
+
+	err := app.setChangeRequestChanges(request)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"change_request": request}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// approveChangeRequestHandler applies a pending change request to the helmet
+// as it is now, recording the reviewer as the author of the update.
+func (app *application) approveChangeRequestHandler(w http.ResponseWriter, r *http.Request) {
+	helmet, request, ok := app.readPendingChangeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Comment string `json:"comment"`
+	}
+
+	// The comment is optional when approving, so an empty body is allowed.
+	if r.ContentLength != 0 {
+		err := app.readJSON(w, r, &input)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	reviewer := app.contextGetUser(r)
+	request.Status = data.ChangeRequestApproved
+	request.ReviewerID = reviewer.ID
+	request.ReviewComment = strings.TrimSpace(input.Comment)
+
+	v := validator.New()
+
+	if data.ValidateChangeRequestReview(v, request); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if !app.applyChangeRequest(w, r, helmet, request) {
+		return
+	}
+
+	err := app.models.Helmets.ApplyChangeRequest(helmet, request)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.saveErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// The response isn't a representation of the helmet alone, so it has no
+	// ETag. Clients revalidate the helmet by getting it again.
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"change_request": request, "helmet": app.helmetView(r, helmet)}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) rejectChangeRequestHandler(w http.ResponseWriter, r *http.Request) {
+	_, request, ok := app.readPendingChangeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Comment string `json:"comment"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	request.Status = data.ChangeRequestRejected
+	request.ReviewerID = app.contextGetUser(r).ID
+	request.ReviewComment = strings.TrimSpace(input.Comment)
+
+	v := validator.New()
+
+	if data.ValidateChangeRequestReview(v, request); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.ChangeRequests.Review(request)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"change_request": request}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// applyChangeRequest applies the patch of request to helmet and checks that
+// the result is a valid helmet, setting the changes of the request. When it
+// isn't a response is sent and false is returned.
+func (app *application) applyChangeRequest(w http.ResponseWriter, r *http.Request, helmet *data.Helmet, request *data.HelmetChangeRequest) bool {
+	current := data.NewHelmetSnapshot(helmet)
+	proposed, err := request.Propose(current)
+	if err != nil {
+		switch {
+		case errors.Is(err, jsonpatch.ErrInvalidPatch):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.failedValidationResponse(w, r, snapshotErrors(err))
+		}
+		return false
+	}
+	proposed.Apply(helmet)
+
+	v := validator.New()
+	data.ValidateHelmet(v, helmet)
+
+	if err := app.checkManufacturer(v, helmet.ManufacturerID); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return false
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return false
+	}
+
+	request.Changes = current.Diff(proposed)
+	return true
+}
+
+// setChangeRequestChanges sets the changes pending requests would make to
+// their helmets as they are now. Requests for helmets in the trash are left
+// without changes.
+func (app *application) setChangeRequestChanges(requests ...*data.HelmetChangeRequest) error {
+	helmets := make(map[int64]*data.Helmet)
+	for _, request := range requests {
+		if request.Status != data.ChangeRequestPending {
+			continue
+		}
+
+		helmet, ok := helmets[request.HelmetID]
+		if !ok {
+			var err error
+			helmet, err = app.models.Helmets.GetWithFieldset(request.HelmetID, data.Fieldset{})
+			if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+				return err
+			}
+			helmets[request.HelmetID] = helmet
+		}
+		if helmet == nil {
+			continue
+		}
+
+		current := data.NewHelmetSnapshot(helmet)
+		proposed, err := request.Propose(current)
+		if err != nil {
+			return err
+		}
+		request.Changes = current.Diff(proposed)
+	}
+	return nil
+}
+
+// readChangeRequest looks up the change request named by the :id and
+// :request route parameters, along with its helmet.
+func (app *application) readChangeRequest(w http.ResponseWriter, r *http.Request) (*data.Helmet, *data.HelmetChangeRequest, bool) {
+	helmet, ok := app.readLiveHelmet(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	requestID, err := app.readInt64Param(r, "request")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, nil, false
+	}
+
+	request, err := app.models.ChangeRequests.Get(helmet.ID, requestID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, nil, false
+	}
+	return helmet, request, true
+}
+
+// readPendingChangeRequest is readChangeRequest for reviews, which can only
+// be made once.
+func (app *application) readPendingChangeRequest(w http.ResponseWriter, r *http.Request) (*data.Helmet, *data.HelmetChangeRequest, bool) {
+	helmet, request, ok := app.readChangeRequest(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	if request.Status != data.ChangeRequestPending {
+		app.errorResponse(w, r, http.StatusConflict, fmt.Sprintf("the change request has already been %s", request.Status))
+		return nil, nil, false
+	}
+	return helmet, request, true
+}
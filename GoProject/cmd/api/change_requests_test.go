@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestApproveChangeRequest(t *testing.T) {
+	app := newTestApplication(t)
+	writer := newTestUser(t, app, "writer@example.com", "mhelmets:read", "mhelmets:write", "mhelmets:publish")
+	contributor := newTestUser(t, app, "contributor@example.com", "mhelmets:read")
+	ts := newTestServer(t, app.routes())
+
+	res, body := ts.do(t, http.MethodPost, "/v1/mhelmets", writer, `{"name":"RPHA 11","year":2020,"material":"carbon","protection":"full face","weight":1.4,"status":"published"}`)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("got status %d creating the helmet: %s", res.StatusCode, body)
+	}
+
+	res, body = ts.do(t, http.MethodPost, "/v1/mhelmets/1/change-requests", contributor, `{"patch":{"name":"RPHA 11 Carbon"}}`)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("got status %d creating the change request: %s", res.StatusCode, body)
+	}
+
+	tests := []struct {
+		name       string
+		action     string
+		wantStatus int
+	}{
+		{"Approve", "approve", http.StatusOK},
+		{"Approve again", "approve", http.StatusConflict},
+		{"Reject after approval", "reject", http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, body := ts.do(t, http.MethodPost, "/v1/mhelmets/1/change-requests/1/"+tt.action, writer, "")
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", res.StatusCode, tt.wantStatus, body)
+			}
+		})
+	}
+
+	res, body = ts.do(t, http.MethodGet, "/v1/mhelmets/1", writer, "")
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d: %s", res.StatusCode, body)
+	}
+
+	var got struct {
+		Helmet struct {
+			Name    string `json:"name"`
+			Version int32  `json:"version"`
+		} `json:"helmet"`
+	}
+	decodeJSON(t, body, &got)
+
+	if got.Helmet.Name != "RPHA 11 Carbon" || got.Helmet.Version != 2 {
+		t.Errorf("got helmet %q at version %d, want %q at version 2", got.Helmet.Name, got.Helmet.Version, "RPHA 11 Carbon")
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"GoProject/internal/data"
+	"GoProject/internal/validator"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// compareMHelmetsHandler returns the helmets listed in the ids query
+// parameter, in that order, along with an attribute by attribute comparison
+// of them. Prices are compared when a currency is given.
+func (app *application) compareMHelmetsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+	currency := strings.ToUpper(app.readString(qs, "currency", ""))
+
+	var ids []int64
+	seen := make(map[int64]bool)
+	for _, s := range app.readCSV(qs, "ids", []string{}) {
+		id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil || id < 1 {
+			v.AddError("ids", "must be a comma-separated list of helmet ids")
+			break
+		}
+		v.Check(!seen[id], "ids", "must not contain duplicate helmets")
+		seen[id] = true
+		ids = append(ids, id)
+	}
+
+	v.Check(len(ids) >= data.MinCompareHelmets && len(ids) <= data.MaxCompareHelmets, "ids", fmt.Sprintf("must list between %d and %d helmets", data.MinCompareHelmets, data.MaxCompareHelmets))
+	v.Check(currency == "" || validator.In(currency, data.SupportedCurrencies...), "currency", "must be one of "+strings.Join(data.SupportedCurrencies, ", "))
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	helmets := make([]*data.Helmet, len(ids))
+	for i, id := range ids {
+		helmet, err := app.models.Helmets.Get(id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				v.AddError("ids", fmt.Sprintf("helmet %d does not exist", id))
+				app.failedValidationResponse(w, r, v.Errors)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		visible, err := app.helmetVisible(r, helmet)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if !visible {
+			v.AddError("ids", fmt.Sprintf("helmet %d does not exist", id))
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+		helmets[i] = helmet
+	}
+
+	err := app.setFavourited(r, helmets...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	comparison := data.CompareHelmets(helmets, currency)
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"helmets": app.helmetViews(r, helmets), "comparison": comparison}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
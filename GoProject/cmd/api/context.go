@@ -8,7 +8,11 @@ import (
 
 type contextKey string
 
-const userContextKey = contextKey("user")
+const (
+	userContextKey         = contextKey("user")
+	apiVersionContextKey   = contextKey("apiVersion")
+	negotiationsContextKey = contextKey("negotiations")
+)
 
 func (app *application) contextSetUser(r *http.Request, user *data.User) *http.Request {
 	ctx := context.WithValue(r.Context(), userContextKey, user)
@@ -22,3 +26,30 @@ func (app *application) contextGetUser(r *http.Request) *data.User {
 	}
 	return user
 }
+
+func (app *application) contextSetAPIVersion(r *http.Request, version int) *http.Request {
+	ctx := context.WithValue(r.Context(), apiVersionContextKey, version)
+	return r.WithContext(ctx)
+}
+
+// contextGetAPIVersion returns the version of the API the request was routed
+// to, which is the first version for requests outside the versioned routes.
+func (app *application) contextGetAPIVersion(r *http.Request) int {
+	version, ok := r.Context().Value(apiVersionContextKey).(int)
+	if !ok {
+		return 1
+	}
+	return version
+}
+
+func (app *application) contextSetNegotiations(r *http.Request, negotiations []negotiation) *http.Request {
+	ctx := context.WithValue(r.Context(), negotiationsContextKey, negotiations)
+	return r.WithContext(ctx)
+}
+
+// contextGetNegotiations returns the encoders negotiateResponse found
+// acceptable for the request, and false for requests it didn't handle.
+func (app *application) contextGetNegotiations(r *http.Request) ([]negotiation, bool) {
+	negotiations, ok := r.Context().Value(negotiationsContextKey).([]negotiation)
+	return negotiations, ok
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"GoProject/internal/msgpack"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"mime"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// errEncodingUnsupported is returned by encoders for responses they can't
+// represent, like single records in CSV.
+var errEncodingUnsupported = errors.New("response can't be represented in the media type")
+
+// encoder writes response envelopes in a media type. The first of mediaTypes
+// is the canonical one, the others are aliases. params holds the parameters
+// of the media range the encoder was negotiated by. listsOnly is set for
+// encoders that can only represent lists of records.
+type encoder struct {
+	mediaTypes []string
+	encode     func(data envelope, params map[string]string) ([]byte, error)
+	listsOnly  bool
+}
+
+// encoderRegistry lists the encoders responses can be written with, in order
+// of preference. The first one is used when the client has no preference.
+type encoderRegistry []*encoder
+
+// responseEncoders are the encoders used by writeResponse. JSON is indented
+// unless it is asked for with compact=true, as in
+// "Accept: application/json; compact=true".
+var responseEncoders = encoderRegistry{
+	{mediaTypes: []string{"application/json"}, encode: encodeJSON},
+	{mediaTypes: []string{"application/xml", "text/xml"}, encode: encodeXML},
+	{mediaTypes: []string{"text/csv"}, encode: encodeCSV, listsOnly: true},
+	{mediaTypes: []string{"application/msgpack", "application/vnd.msgpack", "application/x-msgpack"}, encode: encodeMsgpack},
+}
+
+// mediaTypes returns the media types responses can be written in.
+func (reg encoderRegistry) mediaTypes() []string {
+	types := make([]string, len(reg))
+	for i, e := range reg {
+		types[i] = e.mediaTypes[0]
+	}
+	return types
+}
+
+// forSingleRecords returns the encoders that can represent responses other
+// than lists of records.
+func (reg encoderRegistry) forSingleRecords() encoderRegistry {
+	var encoders encoderRegistry
+	for _, e := range reg {
+		if !e.listsOnly {
+			encoders = append(encoders, e)
+		}
+	}
+	return encoders
+}
+
+// negotiation is an encoder acceptable to a client, with the media type it
+// was matched by and the parameters of the client's media range.
+type negotiation struct {
+	encoder   *encoder
+	mediaType string
+	params    map[string]string
+}
+
+// negotiate returns the encoders acceptable for an Accept header, most
+// preferred first. As in RFC 9110, section 12.5.1, a media type gets the
+// quality value of the most specific range that matches it, so that
+// "text/csv;q=0, */*" accepts anything but CSV. Of two encoders with the
+// same quality value, the one matched by the more specific range comes
+// first, then the one registered first.
+func (reg encoderRegistry) negotiate(accept string) []negotiation {
+	if strings.TrimSpace(accept) == "" {
+		return []negotiation{{encoder: reg[0], mediaType: reg[0].mediaTypes[0]}}
+	}
+
+	type mediaRange struct {
+		mediaType   string
+		params      map[string]string
+		q           float64
+		specificity int
+	}
+
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(value, 64)
+			if err != nil || q < 0 || q > 1 {
+				continue
+			}
+			delete(params, "q")
+		}
+
+		specificity := 2
+		switch {
+		case mediaType == "*/*":
+			specificity = 0
+		case strings.HasSuffix(mediaType, "/*"):
+			specificity = 1
+		case len(params) > 0:
+			specificity = 3
+		}
+		ranges = append(ranges, mediaRange{mediaType: mediaType, params: params, q: q, specificity: specificity})
+	}
+
+	// match returns the range that decides the quality value of mediaType,
+	// or false if no range matches it.
+	match := func(mediaType string) (mediaRange, bool) {
+		var best mediaRange
+		found := false
+		for _, r := range ranges {
+			if r.mediaType != "*/*" && r.mediaType != mediaType && !(strings.HasSuffix(r.mediaType, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(r.mediaType, "*"))) {
+				continue
+			}
+			if !found || r.specificity > best.specificity || (r.specificity == best.specificity && r.q > best.q) {
+				best, found = r, true
+			}
+		}
+		return best, found
+	}
+
+	// Each encoder is negotiated by the alias with the highest quality value.
+	type candidate struct {
+		negotiation
+		decidedBy mediaRange
+	}
+
+	var candidates []candidate
+	for _, e := range reg {
+		var best *candidate
+		for _, mediaType := range e.mediaTypes {
+			r, ok := match(mediaType)
+			if !ok || r.q == 0 {
+				continue
+			}
+			if best == nil || r.q > best.decidedBy.q || (r.q == best.decidedBy.q && r.specificity > best.decidedBy.specificity) {
+				best = &candidate{negotiation{encoder: e, mediaType: mediaType, params: r.params}, r}
+			}
+		}
+		if best != nil {
+			candidates = append(candidates, *best)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i].decidedBy, candidates[j].decidedBy
+		if a.q != b.q {
+			return a.q > b.q
+		}
+		return a.specificity > b.specificity
+	})
+
+	acceptable := make([]negotiation, len(candidates))
+	for i, c := range candidates {
+		acceptable[i] = c.negotiation
+	}
+	return acceptable
+}
+
+func encodeJSON(data envelope, params map[string]string) ([]byte, error) {
+	var js []byte
+	var err error
+	if params["compact"] == "true" {
+		js, err = json.Marshal(data)
+	} else {
+		js, err = json.MarshalIndent(data, "", "\t")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(js, '\n'), nil
+}
+
+// jsonObject is a JSON object with its members in their original order. The
+// XML, CSV and MessagePack encoders work on envelopes marshalled to JSON and
+// parsed back with parseJSON, so that they represent records exactly as JSON
+// does.
+type jsonObject []jsonMember
+
+type jsonMember struct {
+	key   string
+	value interface{}
+}
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, member := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(member.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(member.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// parseJSON marshals data to JSON and parses it into a tree of jsonObject,
+// []interface{}, string, json.Number, bool and nil values.
+func parseJSON(data envelope) (interface{}, error) {
+	js, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.UseNumber()
+	return parseJSONValue(dec)
+}
+
+func parseJSONValue(dec *json.Decoder) (interface{}, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		object := jsonObject{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := parseJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			object = append(object, jsonMember{key: key.(string), value: value})
+		}
+		_, err = dec.Token()
+		return object, err
+	case json.Delim('['):
+		array := []interface{}{}
+		for dec.More() {
+			value, err := parseJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		_, err = dec.Token()
+		return array, err
+	}
+	return token, nil
+}
+
+// xmlNameRX matches the member names that can be used as XML element names
+// as they are. Others, like the currency codes of prices, are written as
+// entry elements with a key attribute.
+var xmlNameRX = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9._-]*$`)
+
+// encodeXML writes the envelope as a response element. Object members become
+// child elements named after their keys, array elements become item
+// elements, and null values are empty elements.
+func encodeXML(data envelope, params map[string]string) ([]byte, error) {
+	tree, err := parseJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "\t")
+	if err := writeXML(enc, xml.StartElement{Name: xml.Name{Local: "response"}}, tree); err != nil {
+		return nil, err
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+func writeXML(enc *xml.Encoder, start xml.StartElement, value interface{}) error {
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case jsonObject:
+		for _, member := range v {
+			child := xml.StartElement{Name: xml.Name{Local: member.key}}
+			if !xmlNameRX.MatchString(member.key) || strings.HasPrefix(strings.ToLower(member.key), "xml") {
+				child = xml.StartElement{Name: xml.Name{Local: "entry"}, Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: member.key}}}
+			}
+			if err := writeXML(enc, child, member.value); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if err := writeXML(enc, xml.StartElement{Name: xml.Name{Local: "item"}}, item); err != nil {
+				return err
+			}
+		}
+	case nil:
+	default:
+		if err := enc.EncodeToken(xml.CharData(scalarString(v))); err != nil {
+			return err
+		}
+	}
+
+	return enc.EncodeToken(start.End())
+}
+
+// encodeCSV writes the records of list responses, one row per record. The
+// envelope must hold exactly one list of objects; other members, like the
+// page metadata, are left out. The header row names the fields of the
+// records in order of first appearance, and nested objects and lists are
+// written as JSON.
+func encodeCSV(data envelope, params map[string]string) ([]byte, error) {
+	tree, err := parseJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []interface{}
+	for _, member := range tree.(jsonObject) {
+		if list, ok := member.value.([]interface{}); ok {
+			if records != nil {
+				return nil, errEncodingUnsupported
+			}
+			records = list
+		}
+	}
+	if records == nil {
+		return nil, errEncodingUnsupported
+	}
+
+	var header []string
+	columns := make(map[string]int)
+	for _, record := range records {
+		object, ok := record.(jsonObject)
+		if !ok {
+			return nil, errEncodingUnsupported
+		}
+		for _, member := range object {
+			if _, ok := columns[member.key]; !ok {
+				columns[member.key] = len(header)
+				header = append(header, member.key)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if len(header) > 0 {
+		w.Write(header)
+	}
+	for _, record := range records {
+		row := make([]string, len(header))
+		for _, member := range record.(jsonObject) {
+			switch v := member.value.(type) {
+			case jsonObject, []interface{}:
+				js, err := json.Marshal(v)
+				if err != nil {
+					return nil, err
+				}
+				row[columns[member.key]] = string(js)
+			case nil:
+			default:
+				row[columns[member.key]] = scalarString(v)
+			}
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+
+	// An empty list is written as an empty body, which mustn't be nil, since
+	// encodeResponse takes a nil body for a response it couldn't encode.
+	return append([]byte{}, buf.Bytes()...), nil
+}
+
+func encodeMsgpack(data envelope, params map[string]string) ([]byte, error) {
+	tree, err := parseJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var enc msgpack.Encoder
+	writeMsgpack(&enc, tree)
+	return enc.Bytes(), nil
+}
+
+func writeMsgpack(enc *msgpack.Encoder, value interface{}) {
+	switch v := value.(type) {
+	case jsonObject:
+		enc.WriteMapHeader(len(v))
+		for _, member := range v {
+			enc.WriteString(member.key)
+			writeMsgpack(enc, member.value)
+		}
+	case []interface{}:
+		enc.WriteArrayHeader(len(v))
+		for _, item := range v {
+			writeMsgpack(enc, item)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			enc.WriteInt(i)
+		} else {
+			f, _ := v.Float64()
+			enc.WriteFloat(f)
+		}
+	case string:
+		enc.WriteString(v)
+	case bool:
+		enc.WriteBool(v)
+	default:
+		enc.WriteNil()
+	}
+}
+
+func scalarString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
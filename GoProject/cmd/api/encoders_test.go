@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name       string
+		accept     string
+		singles    bool
+		wantTypes  []string
+		wantParams map[string]string
+	}{
+		{"No preference", "", false, []string{"application/json"}, nil},
+		{"Exact type", "application/xml", false, []string{"application/xml"}, nil},
+		{"Alias", "text/xml", false, []string{"text/xml"}, nil},
+		{"Quality values", "application/xml;q=0.5, text/csv", false, []string{"text/csv", "application/xml"}, nil},
+		{"Wildcard", "*/*", false, []string{"application/json", "application/xml", "text/csv", "application/msgpack"}, nil},
+		{"Type wildcard", "text/*", false, []string{"text/xml", "text/csv"}, nil},
+		{"Excluded type", "text/csv;q=0, */*", false, []string{"application/json", "application/xml", "application/msgpack"}, nil},
+		{"Excluded type after wildcard", "*/*, text/csv;q=0", false, []string{"application/json", "application/xml", "application/msgpack"}, nil},
+		{"Excluded alias", "text/*, text/xml;q=0", false, []string{"text/csv"}, nil},
+		{"More specific range first", "*/*;q=0.5, application/msgpack;q=0.5", false, []string{"application/msgpack", "application/json", "application/xml", "text/csv"}, nil},
+		{"Best alias", "application/x-msgpack;q=0.2, application/vnd.msgpack;q=0.8", false, []string{"application/vnd.msgpack"}, nil},
+		{"Parameters", "application/json; compact=true", false, []string{"application/json"}, map[string]string{"compact": "true"}},
+		{"Unsupported type", "image/png", false, nil, nil},
+		{"Invalid quality value", "application/xml;q=2", false, nil, nil},
+		{"Single records as CSV", "text/csv", true, nil, nil},
+		{"Single records as anything", "*/*", true, []string{"application/json", "application/xml", "application/msgpack"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoders := responseEncoders
+			if tt.singles {
+				encoders = encoders.forSingleRecords()
+			}
+
+			var got []string
+			negotiations := encoders.negotiate(tt.accept)
+			for _, n := range negotiations {
+				got = append(got, n.mediaType)
+			}
+			if !reflect.DeepEqual(got, tt.wantTypes) {
+				t.Fatalf("got %q, want %q", got, tt.wantTypes)
+			}
+
+			if tt.wantParams != nil && !reflect.DeepEqual(negotiations[0].params, tt.wantParams) {
+				t.Errorf("got params %v, want %v", negotiations[0].params, tt.wantParams)
+			}
+		})
+	}
+}
+
+func TestEncoders(t *testing.T) {
+	type record struct {
+		ID     int64    `json:"id"`
+		Name   string   `json:"name"`
+		Certs  []string `json:"certifications"`
+		Rating *float64 `json:"rating"`
+	}
+
+	list := envelope{
+		"helmets": []record{
+			{ID: 1, Name: "RPHA 11", Certs: []string{"ECE 22.06"}},
+			{ID: 2, Name: "Neotec, 2", Certs: []string{}},
+		},
+		"metadata": map[string]int{"total_records": 2},
+	}
+	single := envelope{"helmet": record{ID: 1, Name: "RPHA 11"}}
+
+	tests := []struct {
+		name    string
+		encode  func(envelope, map[string]string) ([]byte, error)
+		data    envelope
+		params  map[string]string
+		want    string
+		wantErr error
+	}{
+		{"JSON", encodeJSON, single, nil, "{\n\t\"helmet\": {\n\t\t\"id\": 1,\n\t\t\"name\": \"RPHA 11\",\n\t\t\"certifications\": null,\n\t\t\"rating\": null\n\t}\n}\n", nil},
+		{"Compact JSON", encodeJSON, single, map[string]string{"compact": "true"}, `{"helmet":{"id":1,"name":"RPHA 11","certifications":null,"rating":null}}` + "\n", nil},
+		{"XML", encodeXML, envelope{"helmet": record{ID: 1, Name: "RPHA 11", Certs: []string{"ECE 22.06"}}}, nil, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<response>\n\t<helmet>\n\t\t<id>1</id>\n\t\t<name>RPHA 11</name>\n\t\t<certifications>\n\t\t\t<item>ECE 22.06</item>\n\t\t</certifications>\n\t\t<rating></rating>\n\t</helmet>\n</response>\n", nil},
+		{"XML entries", encodeXML, envelope{"counts": map[string]int{"10": 2}}, nil, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<response>\n\t<counts>\n\t\t<entry key=\"10\">2</entry>\n\t</counts>\n</response>\n", nil},
+		{"CSV", encodeCSV, list, nil, "id,name,certifications,rating\n1,RPHA 11,\"[\"\"ECE 22.06\"\"]\",\n2,\"Neotec, 2\",[],\n", nil},
+		{"CSV empty list", encodeCSV, envelope{"helmets": []record{}}, nil, "", nil},
+		{"CSV single record", encodeCSV, single, nil, "", errEncodingUnsupported},
+		{"CSV two lists", encodeCSV, envelope{"a": []record{}, "b": []record{}}, nil, "", errEncodingUnsupported},
+		{"CSV list of scalars", encodeCSV, envelope{"names": []string{"RPHA 11"}}, nil, "", errEncodingUnsupported},
+		{"MessagePack", encodeMsgpack, envelope{"id": 1}, nil, "\x81\xa2id\x01", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.encode(tt.data, tt.params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got == nil {
+				t.Fatal("got a nil body")
+			}
+			if string(got) != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncoderRegistryForSingleRecords(t *testing.T) {
+	types := responseEncoders.forSingleRecords().mediaTypes()
+	if strings.Contains(strings.Join(types, ","), "text/csv") {
+		t.Errorf("got %q, want no CSV", types)
+	}
+	if len(types) != len(responseEncoders)-1 {
+		t.Errorf("got %d media types, want %d", len(types), len(responseEncoders)-1)
+	}
+}
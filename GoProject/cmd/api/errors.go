@@ -1,8 +1,11 @@
 package main
 
 import (
+	"GoProject/internal/data"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 )
 
 func (app *application) logError(r *http.Request, err error) {
@@ -14,7 +17,7 @@ func (app *application) logError(r *http.Request, err error) {
 
 func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message interface{}) {
 	env := envelope{"error": message}
-	err := app.writeJSON(w, status, env, nil)
+	err := app.writeResponse(w, r, status, env, nil)
 	if err != nil {
 		app.logError(r, err)
 		w.WriteHeader(500)
@@ -46,15 +49,43 @@ func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Reques
 	app.errorResponse(w, r, http.StatusBadRequest, err.Error())
 }
 
+func (app *application) unsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request, supported ...string) {
+	message := fmt.Sprintf("the request body must have one of the content types: %s", strings.Join(supported, ", "))
+	app.errorResponse(w, r, http.StatusUnsupportedMediaType, message)
+}
+
+func (app *application) notAcceptableResponse(w http.ResponseWriter, r *http.Request, supported ...string) {
+	message := fmt.Sprintf("the response can only be represented in the content types: %s", strings.Join(supported, ", "))
+	app.errorResponse(w, r, http.StatusNotAcceptable, message)
+}
+
 func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.Request, errors map[string]string) {
 	app.errorResponse(w, r, http.StatusUnprocessableEntity, errors)
 }
 
+// saveErrorResponse sends the response for an error from saving a record
+// that the handler has no more specific response for. Constraint violations
+// are reported as validation errors on the field they concern, anything else
+// as a server error.
+func (app *application) saveErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var constraintErr *data.ConstraintError
+	if errors.As(err, &constraintErr) {
+		app.failedValidationResponse(w, r, map[string]string{constraintErr.Field: constraintErr.Message})
+		return
+	}
+	app.serverErrorResponse(w, r, err)
+}
+
 func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
 	message := "unable to update the record due to an edit conflict, please try again"
 	app.errorResponse(w, r, http.StatusConflict, message)
 }
 
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the record has been modified since you last retrieved it"
+	app.errorResponse(w, r, http.StatusPreconditionFailed, message)
+}
+
 func (app *application) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
 	message := "invalid authentication credentials"
 	app.errorResponse(w, r, http.StatusUnauthorized, message)
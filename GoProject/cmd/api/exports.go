@@ -0,0 +1,139 @@
+package main
+
+import (
+	"GoProject/internal/data"
+	"GoProject/internal/validator"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// exportWriteTimeout replaces the server's WriteTimeout for exports, which
+// can take much longer to send than a regular response.
+const exportWriteTimeout = 10 * time.Minute
+
+var exportContentTypes = map[string]string{
+	"csv":    "text/csv; charset=utf-8",
+	"ndjson": "application/x-ndjson",
+	"json":   "application/json",
+}
+
+func (app *application) exportMHelmetsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.HelmetFilter
+		Format string
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.HelmetFilter = app.readHelmetFilter(qs, v)
+	input.Format = app.readString(qs, "format", "csv")
+
+	v.Check(validator.In(input.Format, "csv", "ndjson", "json"), "format", "must be csv, ndjson or json")
+
+	if data.ValidateHelmetFilter(v, input.HelmetFilter); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.restrictToVisible(r, &input.HelmetFilter); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+
+	buf := bufio.NewWriter(w)
+	csvWriter := csv.NewWriter(buf)
+	count := 0
+
+	// Headers are only sent once the first row has been read, so that a
+	// failing query can still be reported with a regular error response.
+	start := func() error {
+		w.Header().Set("Content-Type", exportContentTypes[input.Format])
+		w.Header().Set("Content-Disposition", `attachment; filename="mhelmets.`+input.Format+`"`)
+		w.WriteHeader(http.StatusOK)
+
+		switch input.Format {
+		case "csv":
+			return csvWriter.Write([]string{"id", "name", "year", "material", "ventilation", "protection", "certifications", "weight", "sun_protection", "manufacturer_id"})
+		case "json":
+			_, err := buf.WriteString("[\n")
+			return err
+		}
+		return nil
+	}
+
+	err := app.models.Helmets.Stream(input.HelmetFilter, func(helmet *data.Helmet) error {
+		if count == 0 {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		count++
+
+		switch input.Format {
+		case "csv":
+			var manufacturerID string
+			if helmet.ManufacturerID != 0 {
+				manufacturerID = strconv.FormatInt(helmet.ManufacturerID, 10)
+			}
+			csvWriter.Write([]string{
+				strconv.FormatInt(helmet.ID, 10),
+				helmet.Name,
+				strconv.FormatInt(int64(helmet.Year), 10),
+				helmet.Material,
+				strconv.FormatBool(helmet.Ventilation),
+				helmet.Protection,
+				data.FormatCertifications(helmet.Certifications),
+				strconv.FormatFloat(helmet.Weight, 'f', -1, 64),
+				strconv.FormatBool(helmet.SunProtection),
+				manufacturerID,
+			})
+			return csvWriter.Error()
+		default:
+			js, err := json.Marshal(app.helmetView(r, helmet))
+			if err != nil {
+				return err
+			}
+			if input.Format == "json" && count > 1 {
+				js = append([]byte(",\n"), js...)
+			}
+			if input.Format == "ndjson" {
+				js = append(js, '\n')
+			}
+			_, err = buf.Write(js)
+			return err
+		}
+	})
+	if err != nil {
+		if count == 0 {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		// The status line has already been sent, so the best we can do is
+		// log the problem and cut the response short.
+		app.logError(r, err)
+		return
+	}
+
+	if count == 0 {
+		if err := start(); err != nil {
+			app.logError(r, err)
+			return
+		}
+	}
+
+	switch input.Format {
+	case "csv":
+		csvWriter.Flush()
+	case "json":
+		buf.WriteString("\n]\n")
+	}
+
+	if err := buf.Flush(); err != nil {
+		app.logError(r, err)
+	}
+}
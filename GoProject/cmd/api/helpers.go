@@ -1,7 +1,9 @@
 package main
 
 import (
+	"GoProject/internal/data"
 	"GoProject/internal/validator"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -14,29 +16,161 @@ import (
 )
 
 func (app *application) readIDParam(r *http.Request) (int64, error) {
+	return app.readInt64Param(r, "id")
+}
+
+func (app *application) readInt64Param(r *http.Request, name string) (int64, error) {
 	params := httprouter.ParamsFromContext(r.Context())
-	id, err := strconv.ParseInt(params.ByName("id"), 10, 64)
+	id, err := strconv.ParseInt(params.ByName(name), 10, 64)
 	if err != nil || id < 1 {
-		return 0, errors.New("invalid id parameter")
+		return 0, fmt.Errorf("invalid %s parameter", name)
 	}
 	return id, nil
 }
 
+// location returns the path of a resource under the version of the API the
+// request was made to, for Location headers.
+func (app *application) location(r *http.Request, format string, args ...interface{}) string {
+	return fmt.Sprintf("/v%d", app.contextGetAPIVersion(r)) + fmt.Sprintf(format, args...)
+}
+
 type envelope map[string]interface{}
 
-func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
-	js, err := json.MarshalIndent(data, "", "\t")
+// writeResponse writes data in the most preferred media type of the
+// request's Accept header that can represent it, with JSON as the default.
+// Error responses that can't be represented in an acceptable media type are
+// written as JSON instead.
+func (app *application) writeResponse(w http.ResponseWriter, r *http.Request, status int, data envelope, headers http.Header) error {
+	body, mediaType, err := app.encodeResponse(r, status, data)
+	if err != nil {
+		return err
+	}
+	if body == nil {
+		app.notAcceptableResponse(w, r, responseEncoders.mediaTypes()...)
+		return nil
+	}
+
+	app.writeBody(w, status, body, mediaType, headers)
+	return nil
+}
+
+// writeHelmet writes helmet as GET represents it, with an ETag computed from
+// the encoded body. Write responses carry the ETag a GET right after them
+// would, so that a helmet from either can be revalidated with If-None-Match,
+// and GET requests whose If-None-Match lists it get a 304 instead.
+func (app *application) writeHelmet(w http.ResponseWriter, r *http.Request, status int, helmet *data.Helmet, headers http.Header) error {
+	err := app.setFavourited(r, helmet)
+	if err != nil {
+		return err
+	}
+
+	body, mediaType, err := app.encodeResponse(r, status, envelope{"helmet": app.helmetView(r, helmet)})
 	if err != nil {
 		return err
 	}
-	js = append(js, '\n')
+	if body == nil {
+		app.notAcceptableResponse(w, r, responseEncoders.mediaTypes()...)
+		return nil
+	}
+
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	headers.Set("ETag", app.etag(helmet.Version, body))
+
+	if match := r.Header.Get("If-None-Match"); r.Method == http.MethodGet && match != "" && app.etagMatches(match, headers.Get("ETag")) {
+		for key, value := range headers {
+			w.Header()[key] = value
+		}
+		w.Header().Add("Vary", "Accept")
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	app.writeBody(w, status, body, mediaType, headers)
+	return nil
+}
+
+// encodeResponse encodes data in the most preferred media type of the
+// request's Accept header that can represent it. A nil body is returned when
+// there is none, unless status is an error's.
+func (app *application) encodeResponse(r *http.Request, status int, data envelope) ([]byte, string, error) {
+	negotiations, ok := app.contextGetNegotiations(r)
+	if !ok {
+		negotiations = responseEncoders.negotiate(r.Header.Get("Accept"))
+	}
+
+	for _, n := range negotiations {
+		body, err := n.encoder.encode(data, n.params)
+		if errors.Is(err, errEncodingUnsupported) {
+			continue
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		return body, n.mediaType, nil
+	}
+
+	if status < 400 {
+		return nil, "", nil
+	}
+	body, err := responseEncoders[0].encode(data, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, responseEncoders[0].mediaTypes[0], nil
+}
+
+func (app *application) writeBody(w http.ResponseWriter, status int, body []byte, mediaType string, headers http.Header) {
 	for key, value := range headers {
 		w.Header()[key] = value
 	}
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Add("Vary", "Accept")
+	w.Header().Set("Content-Type", mediaType)
 	w.WriteHeader(status)
-	w.Write(js)
-	return nil
+	w.Write(body)
+}
+
+// etag is the entity tag of a helmet representation. Besides the version it
+// hashes the body, which also depends on the reviews, prices, images and
+// manufacturer of the helmet, the user, the fieldset, the API version and the
+// media type.
+func (app *application) etag(version int32, body []byte) string {
+	sum := sha256.Sum256(body)
+	return strconv.Quote(fmt.Sprintf("%d-%x", version, sum[:8]))
+}
+
+// etagMatches reports whether an If-None-Match header value lists etag, using
+// the weak comparison where a W/ prefix is ignored.
+func (app *application) etagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// versionMatches reports whether an If-Match header value lists an entity tag
+// of the given helmet version, from any of its representations. Weak tags
+// never match.
+func (app *application) versionMatches(header string, version int32) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		tag, err := strconv.Unquote(candidate)
+		if err != nil {
+			continue
+		}
+		tagVersion, _, _ := strings.Cut(tag, "-")
+		if tagVersion == strconv.FormatInt(int64(version), 10) {
+			return true
+		}
+	}
+	return false
 }
 
 func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
@@ -84,6 +218,24 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst int
 	}
 	return nil
 }
+
+// optionalFloat is a JSON number field of a partial update that tells an
+// explicit null apart from a missing key: Set is true whenever the key was
+// present, and Value is nil when it was null.
+type optionalFloat struct {
+	Set   bool
+	Value *float64
+}
+
+func (o *optionalFloat) UnmarshalJSON(js []byte) error {
+	o.Set = true
+	if string(js) == "null" {
+		o.Value = nil
+		return nil
+	}
+	return json.Unmarshal(js, &o.Value)
+}
+
 func (app *application) readString(qs url.Values, key string, defaultValue string) string {
 	s := qs.Get(key)
 
@@ -120,6 +272,56 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	return i
 }
 
+func (app *application) readFloat(qs url.Values, key string, defaultValue float64, v *validator.Validator) float64 {
+	s := qs.Get(key)
+
+	if s == "" {
+		return defaultValue
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		v.AddError(key, "must be a number")
+		return defaultValue
+	}
+
+	return f
+}
+
+func (app *application) readMoney(qs url.Values, key string, v *validator.Validator) data.Money {
+	s := qs.Get(key)
+
+	if s == "" {
+		return 0
+	}
+
+	m, err := data.ParseMoney(s)
+	if err != nil {
+		v.AddError(key, "must be an amount with at most two decimal places")
+		return 0
+	}
+
+	return m
+}
+
+// readBool returns nil when the key is absent, so that callers can tell "not
+// filtered" apart from false.
+func (app *application) readBool(qs url.Values, key string, v *validator.Validator) *bool {
+	s := qs.Get(key)
+
+	if s == "" {
+		return nil
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "must be a boolean value")
+		return nil
+	}
+
+	return &b
+}
+
 func (app *application) background(fn func()) {
 	app.wg.Add(1)
 	go func() {
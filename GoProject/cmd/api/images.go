@@ -0,0 +1,376 @@
+package main
+
+import (
+	"GoProject/internal/data"
+	"GoProject/internal/storage"
+	"GoProject/internal/thumbnail"
+	"GoProject/internal/validator"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	_ "image/gif"
+)
+
+// maxImagePixels guards against images that are small files but decode to
+// huge bitmaps.
+const maxImagePixels = 40_000_000
+
+// imageExtensions maps the image types that can be uploaded, as sniffed from
+// their content, to the extension they are stored with.
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+func (app *application) listImagesHandler(w http.ResponseWriter, r *http.Request) {
+	helmet, ok := app.readLiveHelmet(w, r)
+	if !ok {
+		return
+	}
+
+	images, err := app.models.Images.GetAllForHelmet(helmet.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"images": images}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// uploadImageHandler accepts a multipart/form-data body with the photo in
+// its "image" field. The file type is sniffed from its content rather than
+// trusted from the request, and thumbnails are generated before responding.
+func (app *application) uploadImageHandler(w http.ResponseWriter, r *http.Request) {
+	helmet, ok := app.readLiveHelmet(w, r)
+	if !ok {
+		return
+	}
+
+	maxBytes := app.config.storage.maxImageBytes
+
+	// Leave room for the multipart boundaries and headers around the file.
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+1_048_576)
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		app.unsupportedMediaTypeResponse(w, r, "multipart/form-data")
+		return
+	}
+
+	var body []byte
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			app.imageReadErrorResponse(w, r, err)
+			return
+		}
+		if part.FormName() != "image" {
+			continue
+		}
+
+		body, err = io.ReadAll(io.LimitReader(part, maxBytes+1))
+		if err != nil {
+			app.imageReadErrorResponse(w, r, err)
+			return
+		}
+		break
+	}
+
+	v := validator.New()
+
+	if v.Check(body != nil, "image", "must be provided"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if int64(len(body)) > maxBytes {
+		app.errorResponse(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("the image must not be larger than %d bytes", maxBytes))
+		return
+	}
+
+	contentType := http.DetectContentType(body)
+	ext, ok := imageExtensions[contentType]
+	if v.Check(ok, "image", "must be a JPEG, PNG or GIF image"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(body))
+	if err == nil && config.Width*config.Height > maxImagePixels {
+		v.AddError("image", fmt.Sprintf("must not have more than %d pixels", maxImagePixels))
+	}
+	var src image.Image
+	if err == nil && v.Valid() {
+		src, _, err = image.Decode(bytes.NewReader(body))
+	}
+	if err != nil {
+		v.AddError("image", "must be a valid image file")
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	img := &data.Image{
+		HelmetID:    helmet.ID,
+		ContentType: contentType,
+		Size:        int64(len(body)),
+		Width:       int32(config.Width),
+		Height:      int32(config.Height),
+		Thumbnails:  make(map[string]data.Thumbnail),
+	}
+
+	err = app.storeImage(img, body, src, ext)
+	if err == nil {
+		err = app.models.Images.Insert(img)
+	}
+	if err != nil {
+		app.deleteImageFiles(img)
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", app.location(r, "/mhelmets/%d/images/%d", helmet.ID, img.ID))
+
+	err = app.writeResponse(w, r, http.StatusCreated, envelope{"image": img}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) imageReadErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		message := fmt.Sprintf("the image must not be larger than %d bytes", app.config.storage.maxImageBytes)
+		app.errorResponse(w, r, http.StatusRequestEntityTooLarge, message)
+		return
+	}
+	app.badRequestResponse(w, r, err)
+}
+
+// storeImage saves the original file and its thumbnails under a random key,
+// recording their keys and URLs on img. JPEG images get JPEG thumbnails and
+// everything else PNG ones, so that transparency survives.
+func (app *application) storeImage(img *data.Image, body []byte, src image.Image, ext string) error {
+	random := make([]byte, 16)
+	_, err := rand.Read(random)
+	if err != nil {
+		return err
+	}
+	base := fmt.Sprintf("mhelmets/%d/%s", img.HelmetID, hex.EncodeToString(random))
+
+	key := base + ext
+	err = app.storage.Put(key, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	img.Keys = append(img.Keys, key)
+	img.URL = app.storage.URL(key)
+
+	for _, name := range thumbnail.Names() {
+		thumb := thumbnail.Generate(src, thumbnail.Sizes[name])
+
+		var buf bytes.Buffer
+		key := fmt.Sprintf("%s_%s", base, name)
+		if ext == ".jpg" {
+			key += ".jpg"
+			err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85})
+		} else {
+			key += ".png"
+			err = png.Encode(&buf, thumb)
+		}
+		if err != nil {
+			return err
+		}
+
+		err = app.storage.Put(key, &buf)
+		if err != nil {
+			return err
+		}
+		img.Keys = append(img.Keys, key)
+		img.Thumbnails[name] = data.Thumbnail{
+			URL:    app.storage.URL(key),
+			Width:  int32(thumb.Rect.Dx()),
+			Height: int32(thumb.Rect.Dy()),
+		}
+	}
+	return nil
+}
+
+// deleteImageFiles removes the stored files of an image. Failures are only
+// logged, since the image record is already gone or was never saved.
+func (app *application) deleteImageFiles(img *data.Image) {
+	for _, key := range img.Keys {
+		err := app.storage.Delete(key)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"key": key})
+		}
+	}
+}
+
+func (app *application) showImageHandler(w http.ResponseWriter, r *http.Request) {
+	img, ok := app.readImage(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.writeResponse(w, r, http.StatusOK, envelope{"image": img}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteImageHandler(w http.ResponseWriter, r *http.Request) {
+	img, ok := app.readImage(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.models.Images.Delete(img.HelmetID, img.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.deleteImageFiles(img)
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": "image successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// reorderImagesHandler sets the display order of a helmet's images. The
+// request lists the ids of all of its images in their new order.
+func (app *application) reorderImagesHandler(w http.ResponseWriter, r *http.Request) {
+	helmet, ok := app.readLiveHelmet(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		ImageIDs []int64 `json:"image_ids"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	images, err := app.models.Images.GetAllForHelmet(helmet.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	listed := make(map[int64]bool)
+	for _, id := range input.ImageIDs {
+		listed[id] = true
+	}
+	valid := len(input.ImageIDs) == len(images) && len(listed) == len(images)
+	for _, img := range images {
+		valid = valid && listed[img.ID]
+	}
+
+	v := validator.New()
+	if v.Check(valid, "image_ids", "must list every image of the helmet exactly once"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Images.Reorder(helmet.ID, input.ImageIDs)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	images, err = app.models.Images.GetAllForHelmet(helmet.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"images": images}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// serveImageHandler serves stored image files. Keys are random and files are
+// never modified once written, so they can be cached indefinitely.
+func (app *application) serveImageHandler(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(httprouter.ParamsFromContext(r.Context()).ByName("key"), "/")
+
+	f, err := app.storage.Get(key)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(key)))
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+
+	_, err = io.Copy(w, f)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// readImage looks up the image named by the :id and :image route
+// parameters. Images of helmets in the trash are treated as missing.
+func (app *application) readImage(w http.ResponseWriter, r *http.Request) (*data.Image, bool) {
+	helmet, ok := app.readLiveHelmet(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	imageID, err := app.readInt64Param(r, "image")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	img, err := app.models.Images.Get(helmet.ID, imageID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+	return img, true
+}
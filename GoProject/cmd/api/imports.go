@@ -0,0 +1,367 @@
+package main
+
+import (
+	"GoProject/internal/data"
+	"GoProject/internal/validator"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	importModeAtomic     = "atomic"
+	importModeBestEffort = "best_effort"
+
+	maxImportBytes = 10 * 1_048_576
+	maxImportRows  = 10_000
+)
+
+// importRow is a helmet read from an import file, along with the line it
+// started on and any errors found while parsing or validating it.
+type importRow struct {
+	line   int
+	helmet *data.Helmet
+	errors map[string]string
+}
+
+type importReport struct {
+	Mode        string                    `json:"mode"`
+	Total       int                       `json:"total"`
+	Imported    int                       `json:"imported"`
+	Failed      int                       `json:"failed"`
+	ImportedIDs []int64                   `json:"imported_ids"`
+	Errors      map[int]map[string]string `json:"errors,omitempty"`
+}
+
+func (app *application) importMHelmetsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := r.URL.Query()
+	mode := app.readString(qs, "mode", importModeAtomic)
+
+	if v.Check(validator.In(mode, importModeAtomic, importModeBestEffort), "mode", "must be atomic or best_effort"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	var parse func(io.Reader) ([]*importRow, error)
+	switch mediaType {
+	case "text/csv":
+		parse = app.readHelmetsCSV
+	case "application/x-ndjson", "application/ndjson":
+		parse = app.readHelmetsNDJSON
+	default:
+		app.unsupportedMediaTypeResponse(w, r, "text/csv", "application/x-ndjson")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+
+	rows, err := parse(r.Body)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			err = fmt.Errorf("body must not be larger than %d bytes", maxImportBytes)
+		}
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	report := importReport{
+		Mode:        mode,
+		Total:       len(rows),
+		ImportedIDs: []int64{},
+		Errors:      make(map[int]map[string]string),
+	}
+
+	// Each manufacturer is only looked up once, however many rows refer to it.
+	manufacturers := make(map[int64]map[string]string)
+
+	var valid []*importRow
+	for _, row := range rows {
+		if len(row.errors) == 0 {
+			v := validator.New()
+			data.ValidateHelmet(v, row.helmet)
+
+			id := row.helmet.ManufacturerID
+			if _, checked := manufacturers[id]; !checked {
+				mv := validator.New()
+				if err := app.checkManufacturer(mv, id); err != nil {
+					app.serverErrorResponse(w, r, err)
+					return
+				}
+				manufacturers[id] = mv.Errors
+			}
+			for key, message := range manufacturers[id] {
+				v.AddError(key, message)
+			}
+
+			row.errors = v.Errors
+		}
+
+		if len(row.errors) > 0 {
+			report.Errors[row.line] = row.errors
+			continue
+		}
+		valid = append(valid, row)
+	}
+
+	if mode == importModeAtomic {
+		if len(report.Errors) > 0 {
+			report.Failed = len(report.Errors)
+			app.errorResponse(w, r, http.StatusUnprocessableEntity, report)
+			return
+		}
+
+		helmets := make([]*data.Helmet, len(valid))
+		for i, row := range valid {
+			helmets[i] = row.helmet
+		}
+
+		err = app.models.Helmets.InsertMany(helmets)
+		if err != nil {
+			var rowErr *data.RowError
+			var constraintErr *data.ConstraintError
+			if errors.As(err, &rowErr) && errors.As(rowErr.Err, &constraintErr) {
+				report.Errors[valid[rowErr.Index].line] = map[string]string{constraintErr.Field: constraintErr.Message}
+				report.Failed = len(report.Errors)
+				app.errorResponse(w, r, http.StatusUnprocessableEntity, report)
+				return
+			}
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		for _, helmet := range helmets {
+			report.ImportedIDs = append(report.ImportedIDs, helmet.ID)
+		}
+		report.Imported = len(helmets)
+
+		err = app.writeResponse(w, r, http.StatusCreated, envelope{"import": report}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	for _, row := range valid {
+		err := app.models.Helmets.Insert(row.helmet)
+		if err != nil {
+			var constraintErr *data.ConstraintError
+			if errors.As(err, &constraintErr) {
+				report.Errors[row.line] = map[string]string{constraintErr.Field: constraintErr.Message}
+				continue
+			}
+			app.logError(r, err)
+			report.Errors[row.line] = map[string]string{"row": "could not be saved"}
+			continue
+		}
+		report.ImportedIDs = append(report.ImportedIDs, row.helmet.ID)
+	}
+	report.Imported = len(report.ImportedIDs)
+	report.Failed = len(report.Errors)
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"import": report}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readHelmetsCSV reads helmets from a CSV file whose first record is a header
+// naming the columns, using the same names as the JSON representation.
+func (app *application) readHelmetsCSV(body io.Reader) ([]*importRow, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("body must not be empty")
+		}
+		return nil, fmt.Errorf("body contains badly-formed CSV: %w", err)
+	}
+
+	// The id column written by exports is accepted so that an export can be
+	// imported again, but its values are ignored.
+	columns := make(map[string]int)
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if !validator.In(column, "id", "name", "year", "material", "ventilation", "protection", "certifications", "weight", "sun_protection", "manufacturer_id") {
+			return nil, fmt.Errorf("CSV header contains unknown column %q", column)
+		}
+		if _, exists := columns[column]; exists {
+			return nil, fmt.Errorf("CSV header contains duplicate column %q", column)
+		}
+		columns[column] = i
+	}
+
+	var rows []*importRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("body contains badly-formed CSV: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := &importRow{line: line, helmet: &data.Helmet{Status: data.StatusDraft}, errors: make(map[string]string)}
+		if errors.Is(err, csv.ErrFieldCount) {
+			row.errors["row"] = fmt.Sprintf("must have %d fields", len(header))
+			rows = append(rows, row)
+			continue
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		row.helmet.Name = field("name")
+		row.helmet.Material = field("material")
+		row.helmet.Protection = field("protection")
+		row.helmet.Certifications = parseImportCertifications(row.errors, "certifications", field("certifications"))
+		row.helmet.Year = int32(parseImportInt(row.errors, "year", field("year")))
+		row.helmet.Weight = parseImportFloat(row.errors, "weight", field("weight"))
+		row.helmet.Ventilation = parseImportBool(row.errors, "ventilation", field("ventilation"))
+		row.helmet.SunProtection = parseImportBool(row.errors, "sun_protection", field("sun_protection"))
+		row.helmet.ManufacturerID = int64(parseImportInt(row.errors, "manufacturer_id", field("manufacturer_id")))
+
+		rows = append(rows, row)
+		if len(rows) > maxImportRows {
+			return nil, fmt.Errorf("body must not contain more than %d helmets", maxImportRows)
+		}
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("body must contain at least one helmet")
+	}
+	return rows, nil
+}
+
+// readHelmetsNDJSON reads one JSON helmet per line. Blank lines are skipped.
+func (app *application) readHelmetsNDJSON(body io.Reader) ([]*importRow, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1_048_576)
+
+	var rows []*importRow
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		var input struct {
+			Name           string               `json:"name"`
+			Year           int32                `json:"year"`
+			Material       string               `json:"material"`
+			Ventilation    bool                 `json:"ventilation"`
+			Protection     string               `json:"protection"`
+			Certifications []data.Certification `json:"certifications"`
+			Weight         float64              `json:"weight"`
+			SunProtection  bool                 `json:"sun_protection"`
+			ManufacturerID int64                `json:"manufacturer_id"`
+		}
+
+		row := &importRow{line: line, errors: make(map[string]string)}
+
+		dec := json.NewDecoder(bytes.NewReader(text))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&input); err != nil {
+			row.errors["json"] = err.Error()
+		} else if dec.More() {
+			row.errors["json"] = "line must only contain a single JSON value"
+		}
+
+		row.helmet = &data.Helmet{
+			Name:           input.Name,
+			Year:           input.Year,
+			Material:       input.Material,
+			Ventilation:    input.Ventilation,
+			Protection:     input.Protection,
+			Certifications: input.Certifications,
+			Weight:         input.Weight,
+			SunProtection:  input.SunProtection,
+			ManufacturerID: input.ManufacturerID,
+			Status:         data.StatusDraft,
+		}
+
+		rows = append(rows, row)
+		if len(rows) > maxImportRows {
+			return nil, fmt.Errorf("body must not contain more than %d helmets", maxImportRows)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, fmt.Errorf("line %d is longer than 1048576 bytes", line+1)
+		}
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("body must contain at least one helmet")
+	}
+	return rows, nil
+}
+
+func parseImportInt(errs map[string]string, key, value string) int {
+	if value == "" {
+		return 0
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		errs[key] = "must be an integer value"
+	}
+	return i
+}
+
+// parseImportCertifications parses certifications in the format written by
+// exports, like "ece-22.06;sharp:4".
+func parseImportCertifications(errs map[string]string, key, value string) []data.Certification {
+	certifications, err := data.ParseCertifications(value)
+	if err != nil {
+		errs[key] = `must be a list of standards separated by ";", like "ece-22.06;sharp:4"`
+	}
+	return certifications
+}
+
+func parseImportFloat(errs map[string]string, key, value string) float64 {
+	if value == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		errs[key] = "must be a number"
+	}
+	return f
+}
+
+func parseImportBool(errs map[string]string, key, value string) bool {
+	switch strings.ToLower(value) {
+	case "", "0", "f", "false", "n", "no":
+		return false
+	case "1", "t", "true", "y", "yes":
+		return true
+	}
+	errs[key] = "must be a boolean value"
+	return false
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestImportMHelmets(t *testing.T) {
+	app := newTestApplication(t)
+	writer := newTestUser(t, app, "writer@example.com", "mhelmets:read", "mhelmets:write")
+	ts := newTestServer(t, app.routes())
+
+	header := "name,year,material,protection,weight\n"
+
+	tests := []struct {
+		name         string
+		query        string
+		contentType  string
+		body         string
+		wantStatus   int
+		wantImported int
+		wantErrors   map[string]map[string]string
+	}{
+		{
+			name:         "Atomic",
+			contentType:  "text/csv",
+			body:         header + "RPHA 11,2020,carbon,full face,1.4\nNeotec,2019,fibreglass,modular,1.7\n",
+			wantStatus:   http.StatusCreated,
+			wantImported: 2,
+		},
+		{
+			name:        "Atomic with a weight out of range",
+			contentType: "text/csv",
+			body:        header + "RPHA 11,2020,carbon,full face,1.4\nAnvil,2019,steel,full face,3.2\n",
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantErrors:  map[string]map[string]string{"3": {"weight": "must be between 0.5 and 2.5"}},
+		},
+		{
+			name:        "Atomic with a year out of range",
+			contentType: "application/x-ndjson",
+			body:        `{"name":"Pudding basin","year":1930,"material":"cork","protection":"open face","weight":0.9}` + "\n",
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantErrors:  map[string]map[string]string{"1": {"year": "must not be before 1953"}},
+		},
+		{
+			name:         "Best effort",
+			query:        "?mode=best_effort",
+			contentType:  "application/x-ndjson",
+			body:         `{"name":"Anvil","year":2019,"material":"steel","protection":"full face","weight":3.2}` + "\n" + `{"name":"RPHA 11","year":2020,"material":"carbon","protection":"full face","weight":1.4}` + "\n",
+			wantStatus:   http.StatusOK,
+			wantImported: 1,
+			wantErrors:   map[string]map[string]string{"1": {"weight": "must be between 0.5 and 2.5"}},
+		},
+		{
+			name:        "Unsupported content type",
+			contentType: "application/json",
+			body:        `[]`,
+			wantStatus:  http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, body := ts.do(t, http.MethodPost, "/v1/mhelmets/import"+tt.query, writer, tt.body, "Content-Type", tt.contentType)
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", res.StatusCode, tt.wantStatus, body)
+			}
+
+			if res.StatusCode == http.StatusUnsupportedMediaType {
+				return
+			}
+
+			var got struct {
+				Import *importReportJSON `json:"import"`
+				Error  *importReportJSON `json:"error"`
+			}
+			decodeJSON(t, body, &got)
+
+			report := got.Import
+			if report == nil {
+				report = got.Error
+			}
+			if report == nil {
+				t.Fatalf("response has no import report: %s", body)
+			}
+
+			if report.Imported != tt.wantImported {
+				t.Errorf("got %d imported, want %d", report.Imported, tt.wantImported)
+			}
+			if len(report.Errors) != 0 || len(tt.wantErrors) != 0 {
+				if !reflect.DeepEqual(report.Errors, tt.wantErrors) {
+					t.Errorf("got errors %v, want %v", report.Errors, tt.wantErrors)
+				}
+			}
+		})
+	}
+}
+
+type importReportJSON struct {
+	Imported int                          `json:"imported"`
+	Errors   map[string]map[string]string `json:"errors"`
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"GoProject/internal/data"
+	"GoProject/internal/validator"
+	"errors"
+	"net/http"
+)
+
+func (app *application) createManufacturerHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name    string `json:"name"`
+		Country string `json:"country"`
+		Website string `json:"website"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	manufacturer := &data.Manufacturer{
+		Name:    input.Name,
+		Country: input.Country,
+		Website: input.Website,
+	}
+
+	v := validator.New()
+
+	if data.ValidateManufacturer(v, manufacturer); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Manufacturers.Insert(manufacturer)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateManufacturer):
+			v.AddError("name", "a manufacturer with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.saveErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", app.location(r, "/manufacturers/%d", manufacturer.ID))
+
+	err = app.writeResponse(w, r, http.StatusCreated, envelope{"manufacturer": manufacturer}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showManufacturerHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	manufacturer, err := app.models.Manufacturers.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"manufacturer": manufacturer}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateManufacturerHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	manufacturer, err := app.models.Manufacturers.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Name    *string `json:"name"`
+		Country *string `json:"country"`
+		Website *string `json:"website"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		manufacturer.Name = *input.Name
+	}
+	if input.Country != nil {
+		manufacturer.Country = *input.Country
+	}
+	if input.Website != nil {
+		manufacturer.Website = *input.Website
+	}
+
+	v := validator.New()
+	if data.ValidateManufacturer(v, manufacturer); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Manufacturers.Update(manufacturer)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateManufacturer):
+			v.AddError("name", "a manufacturer with this name already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.saveErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"manufacturer": manufacturer}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteManufacturerHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Manufacturers.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrManufacturerInUse):
+			app.errorResponse(w, r, http.StatusConflict, "the manufacturer still has helmets, including any in the trash, and can't be deleted")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": "manufacturer successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listManufacturersHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name string
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.Name = app.readString(qs, "name", "")
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "name")
+	input.Filters.SortSafelist = []string{"id", "name", "country", "-id", "-name", "-country"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	manufacturers, metadata, err := app.models.Manufacturers.GetAll(input.Name, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"manufacturers": manufacturers, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// checkManufacturer adds a validation error when a helmet refers to a
+// manufacturer that doesn't exist, rather than letting the insert fail on the
+// foreign key.
+func (app *application) checkManufacturer(v *validator.Validator, id int64) error {
+	if id <= 0 {
+		return nil
+	}
+
+	_, err := app.models.Manufacturers.Get(id)
+	if errors.Is(err, data.ErrRecordNotFound) {
+		v.AddError("manufacturer_id", "must refer to an existing manufacturer")
+		return nil
+	}
+	return err
+}
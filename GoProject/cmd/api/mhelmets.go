@@ -4,19 +4,25 @@ import (
 	"GoProject/internal/data"
 	"GoProject/internal/validator"
 	"errors"
-	"fmt"
+	"mime"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 )
 
 func (app *application) createMHelmetHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Name          string  `json:"name"`
-		Year          int32   `json:"year"`
-		Material      string  `json:"material"`
-		Ventilation   bool    `json:"ventilation"`
-		Protection    string  `json:"protection"`
-		Weight        float64 `json:"weight"`
-		SunProtection bool    `json:"sun_protection"`
+		Name           string               `json:"name"`
+		Year           int32                `json:"year"`
+		Material       string               `json:"material"`
+		Ventilation    bool                 `json:"ventilation"`
+		Protection     string               `json:"protection"`
+		Certifications []data.Certification `json:"certifications"`
+		Weight         float64              `json:"weight"`
+		SunProtection  bool                 `json:"sun_protection"`
+		ManufacturerID int64                `json:"manufacturer_id"`
+		Status         string               `json:"status"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -25,33 +31,51 @@ func (app *application) createMHelmetHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	if input.Status == "" {
+		input.Status = data.StatusDraft
+	}
+
 	helmet := &data.Helmet{
-		Name:          input.Name,
-		Year:          int32(input.Year),
-		Material:      input.Material,
-		Ventilation:   input.Ventilation,
-		Protection:    input.Protection,
-		Weight:        input.Weight,
-		SunProtection: input.SunProtection,
+		Name:           input.Name,
+		Year:           int32(input.Year),
+		Material:       input.Material,
+		Ventilation:    input.Ventilation,
+		Protection:     input.Protection,
+		Certifications: input.Certifications,
+		Weight:         input.Weight,
+		SunProtection:  input.SunProtection,
+		ManufacturerID: input.ManufacturerID,
+		Status:         input.Status,
 	}
 
 	v := validator.New()
+	data.ValidateHelmet(v, helmet)
+	data.ValidateStatusChange(v, data.StatusDraft, helmet)
+
+	if err := app.checkManufacturer(v, helmet.ManufacturerID); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
 
-	if data.ValidateHelmet(v, helmet); !v.Valid() {
+	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
+	if data.RequiresPublishPermission(data.StatusDraft, helmet) && !app.requireHelmetPublisher(w, r) {
+		return
+	}
+
 	err = app.models.Helmets.Insert(helmet)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		app.saveErrorResponse(w, r, err)
 		return
 	}
 
 	headers := make(http.Header)
-	headers.Set("Location", fmt.Sprintf("/v1/movies/%d", helmet.ID))
+	headers.Set("Location", app.location(r, "/mhelmets/%d", helmet.ID))
 
-	err = app.writeJSON(w, http.StatusCreated, envelope{"helmet": helmet}, headers)
+	err = app.writeHelmet(w, r, http.StatusCreated, helmet, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -64,7 +88,15 @@ func (app *application) showMHelmetHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	helmet, err := app.models.Helmets.Get(id)
+	v := validator.New()
+	fieldset := app.readHelmetFieldset(r)
+
+	if data.ValidateFieldset(v, fieldset); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	helmet, err := app.models.Helmets.GetWithFieldset(id, fieldset)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -75,7 +107,14 @@ func (app *application) showMHelmetHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"helmet": helmet}, nil)
+	if !app.requireHelmetVisible(w, r, helmet) {
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Accept-Patch", acceptPatch)
+
+	err = app.writeHelmet(w, r, http.StatusOK, helmet, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -99,20 +138,78 @@ func (app *application) updateMHelmetHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	if match := r.Header.Get("If-Match"); match != "" && !app.versionMatches(match, helmet.Version) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	// Besides plain JSON, which only changes the fields present in it, the
+	// helmet can be patched with a JSON Merge Patch or JSON Patch document.
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case mergePatchMediaType, jsonPatchMediaType:
+		if !app.patchHelmet(w, r, helmet, mediaType) {
+			return
+		}
+	default:
+		if !app.readHelmetUpdate(w, r, helmet) {
+			return
+		}
+	}
+
+	v := validator.New()
+	data.ValidateHelmet(v, helmet)
+
+	if err := app.checkManufacturer(v, helmet.ManufacturerID); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Helmets.Update(helmet, app.contextGetUser(r).ID, data.RevisionActionUpdate)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.saveErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Accept-Patch", acceptPatch)
+
+	err = app.writeHelmet(w, r, http.StatusOK, helmet, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readHelmetUpdate applies a plain JSON update to helmet. Only the fields
+// present in it are changed. When the body can't be read a response is sent
+// and false is returned.
+func (app *application) readHelmetUpdate(w http.ResponseWriter, r *http.Request, helmet *data.Helmet) bool {
 	var input struct {
-		Name          *string  `json:"name"`
-		Year          *int32   `json:"year"`
-		Material      *string  `json:"material"`
-		Ventilation   *bool    `json:"ventilation"`
-		Protection    *string  `json:"protection"`
-		Weight        *float64 `json:"weight"`
-		SunProtection *bool    `json:"sun_protection"`
+		Name           *string               `json:"name"`
+		Year           *int32                `json:"year"`
+		Material       *string               `json:"material"`
+		Ventilation    *bool                 `json:"ventilation"`
+		Protection     *string               `json:"protection"`
+		Certifications *[]data.Certification `json:"certifications"`
+		Weight         *float64              `json:"weight"`
+		SunProtection  *bool                 `json:"sun_protection"`
+		ManufacturerID *int64                `json:"manufacturer_id"`
 	}
 
-	err = app.readJSON(w, r, &input)
+	err := app.readJSON(w, r, &input)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
-		return
+		return false
 	}
 
 	if input.Name != nil {
@@ -130,31 +227,87 @@ func (app *application) updateMHelmetHandler(w http.ResponseWriter, r *http.Requ
 	if input.Protection != nil {
 		helmet.Protection = *input.Protection
 	}
+	if input.Certifications != nil {
+		helmet.Certifications = *input.Certifications
+	}
 	if input.Weight != nil {
 		helmet.Weight = *input.Weight
 	}
 	if input.SunProtection != nil {
 		helmet.SunProtection = *input.SunProtection
 	}
+	if input.ManufacturerID != nil {
+		helmet.ManufacturerID = *input.ManufacturerID
+	}
+	return true
+}
+
+// updateMHelmetStatusHandler moves a helmet through the editorial workflow
+// and sets its publishing schedule. Leaving publish_at or unpublish_at out
+// clears it.
+func (app *application) updateMHelmetStatusHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	helmet, err := app.models.Helmets.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if match := r.Header.Get("If-Match"); match != "" && !app.versionMatches(match, helmet.Version) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Status      string     `json:"status"`
+		PublishAt   *time.Time `json:"publish_at"`
+		UnpublishAt *time.Time `json:"unpublish_at"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	from := helmet.Status
+	helmet.Status = input.Status
+	helmet.PublishAt = input.PublishAt
+	helmet.UnpublishAt = input.UnpublishAt
 
 	v := validator.New()
-	if data.ValidateHelmet(v, helmet); !v.Valid() {
+
+	if data.ValidateStatusChange(v, from, helmet); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err = app.models.Helmets.Update(helmet)
+	if data.RequiresPublishPermission(from, helmet) && !app.requireHelmetPublisher(w, r) {
+		return
+	}
+
+	err = app.models.Helmets.UpdateStatus(helmet, app.contextGetUser(r).ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
 			app.editConflictResponse(w, r)
 		default:
-			app.serverErrorResponse(w, r, err)
+			app.saveErrorResponse(w, r, err)
 		}
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"helmet": helmet}, nil)
+	err = app.writeHelmet(w, r, http.StatusOK, helmet, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -167,18 +320,41 @@ func (app *application) deleteMHelmetHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	err = app.models.Helmets.Delete(id)
+	var version int32
+
+	if match := r.Header.Get("If-Match"); match != "" {
+		helmet, err := app.models.Helmets.Get(id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		if !app.versionMatches(match, helmet.Version) {
+			app.preconditionFailedResponse(w, r)
+			return
+		}
+		version = helmet.Version
+	}
+
+	err = app.models.Helmets.Delete(id, version)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
 			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.preconditionFailedResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"message": "motorcycle helmet successfully deleted"}, nil)
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": "motorcycle helmet successfully moved to trash"}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -186,36 +362,296 @@ func (app *application) deleteMHelmetHandler(w http.ResponseWriter, r *http.Requ
 
 func (app *application) listMHelmetsHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Name       string
-		Material   string
-		Protection string
+		data.HelmetFilter
 		data.Filters
+		data.Fieldset
+		Facets []string
 	}
 	v := validator.New()
 	qs := r.URL.Query()
-	input.Name = app.readString(qs, "name", "")
-	input.Material = app.readString(qs, "material", "")
-	input.Protection = app.readString(qs, "protection", "")
+	input.HelmetFilter = app.readHelmetFilter(qs, v)
+	input.Fieldset = app.readHelmetFieldset(r)
+	input.Facets = app.readCSV(qs, "facets", []string{})
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
 	input.Filters.Sort = app.readString(qs, "sort", "id")
-	input.Filters.SortSafelist = []string{"id", "name", "year", "material", "ventilation", "protection", "weight", "sun_protection",
-		"-id", "-name", "-year", "-material", "-ventilation", "-protection", "-weight", "-sun_protection"}
+	input.Filters.Cursor = app.readString(qs, "cursor", "")
+	input.Filters.SortSafelist = []string{"id", "name", "year", "material", "ventilation", "protection", "weight", "sun_protection", "manufacturer", "price", "rating",
+		"-id", "-name", "-year", "-material", "-ventilation", "-protection", "-weight", "-sun_protection", "-manufacturer", "-price", "-rating"}
+
+	if input.Q != "" {
+		input.Filters.Sort = app.readString(qs, "sort", "relevance")
+		input.Filters.SortSafelist = append(input.Filters.SortSafelist, "relevance")
+	}
+
+	data.ValidateHelmetFilter(v, input.HelmetFilter)
+	data.ValidateFieldset(v, input.Fieldset)
+	data.ValidateFacets(v, input.Facets)
+	v.Check(input.Currency != "" || strings.TrimPrefix(input.Filters.Sort, "-") != "price", "sort", "price sorting must be used together with currency")
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.restrictToVisible(r, &input.HelmetFilter); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	helmets, metadata, err := app.models.Helmets.GetAll(input.HelmetFilter, input.Filters, input.Fieldset)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrInvalidCursor):
+			v.AddError("cursor", "must be a cursor returned in the metadata of a previous page")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.setFavourited(r, helmets...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"helmets": app.helmetViews(r, helmets), "metadata": metadata}
+
+	if len(input.Facets) > 0 {
+		facets, err := app.models.Helmets.GetFacets(input.HelmetFilter, input.Facets)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		env["facets"] = facets
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+}
+
+// readHelmetFieldset reads the fields= and include= parameters of the helmet
+// listing and show endpoints. An empty include= embeds nothing, while leaving
+// it out embeds the default relations.
+func (app *application) readHelmetFieldset(r *http.Request) data.Fieldset {
+	qs := r.URL.Query()
+	include := data.DefaultHelmetIncludes
+	if qs.Has("include") {
+		include = app.readCSV(qs, "include", []string{})
+	}
+
+	safelist := data.HelmetFieldSafelist
+	if app.contextGetAPIVersion(r) >= 2 {
+		safelist = data.HelmetV2FieldSafelist
+	}
+
+	return data.Fieldset{
+		Fields:          app.readCSV(qs, "fields", nil),
+		FieldsSafelist:  safelist,
+		Include:         include,
+		IncludeSafelist: data.HelmetIncludeSafelist,
+	}
+}
+
+// readHelmetFilter reads the filters shared by the helmet listing and export
+// from the query string.
+func (app *application) readHelmetFilter(qs url.Values, v *validator.Validator) data.HelmetFilter {
+	return data.HelmetFilter{
+		Name:           app.readString(qs, "name", ""),
+		Material:       app.readString(qs, "material", ""),
+		Protection:     app.readString(qs, "protection", ""),
+		Q:              app.readString(qs, "q", ""),
+		WeightMin:      app.readFloat(qs, "weight_min", 0, v),
+		WeightMax:      app.readFloat(qs, "weight_max", 0, v),
+		YearMin:        int32(app.readInt(qs, "year_min", 0, v)),
+		YearMax:        int32(app.readInt(qs, "year_max", 0, v)),
+		Ventilation:    app.readBool(qs, "ventilation", v),
+		SunProtection:  app.readBool(qs, "sun_protection", v),
+		ManufacturerID: int64(app.readInt(qs, "manufacturer_id", 0, v)),
+		Size:           strings.ToUpper(app.readString(qs, "size", "")),
+		InStock:        app.readBool(qs, "in_stock", v),
+		Currency:       strings.ToUpper(app.readString(qs, "currency", "")),
+		PriceMin:       app.readMoney(qs, "price_min", v),
+		PriceMax:       app.readMoney(qs, "price_max", v),
+		Status:         app.readString(qs, "status", ""),
+
+		CertificationsAny: app.readCSV(qs, "certifications_any", nil),
+		CertificationsAll: app.readCSV(qs, "certifications_all", nil),
+	}
+}
+
+func (app *application) listTrashedMHelmetsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-deleted_at")
+	input.Filters.SortSafelist = []string{"id", "name", "deleted_at", "-id", "-name", "-deleted_at"}
 
 	if data.ValidateFilters(v, input.Filters); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	helmets, metadata, err := app.models.Helmets.GetAll(input.Name, input.Material, input.Protection, input.Filters)
+	helmets, metadata, err := app.models.Helmets.GetAllDeleted(input.Filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"helmets": helmets, "metadata": metadata}, nil)
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"helmets": app.helmetViews(r, helmets), "metadata": metadata}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
+}
+
+func (app *application) restoreMHelmetHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
 
+	err = app.models.Helmets.Restore(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	helmet, err := app.models.Helmets.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeHelmet(w, r, http.StatusOK, helmet, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) purgeMHelmetHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// The image records go with the helmet, so their files have to be looked
+	// up beforehand.
+	images, err := app.models.Images.GetAllForHelmet(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Helmets.Purge(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	for _, img := range images {
+		app.deleteImageFiles(img)
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": "motorcycle helmet permanently deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// requireHelmetPublisher sends a not permitted response and returns false
+// unless the user making the request holds mhelmets:publish.
+func (app *application) requireHelmetPublisher(w http.ResponseWriter, r *http.Request) bool {
+	permitted, err := app.userHasPermission(r, "mhelmets:publish")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return false
+	}
+	if !permitted {
+		app.notPermittedResponse(w, r)
+		return false
+	}
+	return true
+}
+
+// helmetVisible reports whether the user making the request can see helmet.
+// Helmets that aren't published are only shown to users with mhelmets:write.
+func (app *application) helmetVisible(r *http.Request, helmet *data.Helmet) (bool, error) {
+	if helmet.Status == data.StatusPublished {
+		return true, nil
+	}
+	return app.userHasPermission(r, "mhelmets:write")
+}
+
+// requireHelmetVisible sends a not found response and returns false if the
+// user making the request can't see helmet.
+func (app *application) requireHelmetVisible(w http.ResponseWriter, r *http.Request, helmet *data.Helmet) bool {
+	visible, err := app.helmetVisible(r, helmet)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return false
+	}
+	if !visible {
+		app.notFoundResponse(w, r)
+		return false
+	}
+	return true
+}
+
+// restrictToVisible limits filter to published helmets, unless the user
+// making the request can see the others and filter by status themselves.
+func (app *application) restrictToVisible(r *http.Request, filter *data.HelmetFilter) error {
+	permitted, err := app.userHasPermission(r, "mhelmets:write")
+	if err != nil {
+		return err
+	}
+	if !permitted {
+		filter.Status = data.StatusPublished
+	}
+	return nil
+}
+
+// helmetView returns helmet in its representation in the version of the API
+// the request was made to.
+func (app *application) helmetView(r *http.Request, helmet *data.Helmet) interface{} {
+	if app.contextGetAPIVersion(r) >= 2 {
+		return (*data.HelmetV2)(helmet)
+	}
+	return helmet
+}
+
+func (app *application) helmetViews(r *http.Request, helmets []*data.Helmet) interface{} {
+	if app.contextGetAPIVersion(r) < 2 {
+		return helmets
+	}
+	views := make([]*data.HelmetV2, len(helmets))
+	for i, helmet := range helmets {
+		views[i] = (*data.HelmetV2)(helmet)
+	}
+	return views
 }
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCreateMHelmet(t *testing.T) {
+	app := newTestApplication(t)
+	writer := newTestUser(t, app, "writer@example.com", "mhelmets:read", "mhelmets:write")
+	reader := newTestUser(t, app, "reader@example.com", "mhelmets:read")
+	ts := newTestServer(t, app.routes())
+
+	valid := `{"name":"RPHA 11","year":2020,"material":"carbon","protection":"full face","weight":1.4}`
+
+	tests := []struct {
+		name       string
+		token      string
+		body       string
+		wantStatus int
+	}{
+		{"Valid", writer, valid, http.StatusCreated},
+		{"Anonymous", "", valid, http.StatusUnauthorized},
+		{"Without write permission", reader, valid, http.StatusForbidden},
+		{"Empty name", writer, `{"name":"","year":2020,"material":"carbon","protection":"full face","weight":1.4}`, http.StatusUnprocessableEntity},
+		{"Year before helmets", writer, `{"name":"Old","year":1880,"material":"leather","protection":"open face","weight":1.4}`, http.StatusUnprocessableEntity},
+		{"Publishing without permission", writer, `{"name":"RPHA 11","year":2020,"material":"carbon","protection":"full face","weight":1.4,"status":"published"}`, http.StatusForbidden},
+		{"Unknown field", writer, `{"name":"RPHA 11","colour":"red"}`, http.StatusBadRequest},
+		{"Malformed JSON", writer, `{"name":`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, body := ts.do(t, http.MethodPost, "/v1/mhelmets", tt.token, tt.body)
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("got status %d, want %d: %s", res.StatusCode, tt.wantStatus, body)
+			}
+		})
+	}
+}
+
+func TestCreateMHelmetLocation(t *testing.T) {
+	app := newTestApplication(t)
+	writer := newTestUser(t, app, "writer@example.com", "mhelmets:read", "mhelmets:write")
+	ts := newTestServer(t, app.routes())
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		body         string
+		wantLocation string
+	}{
+		{"Version 1", "/v1/mhelmets", `{"name":"RPHA 11","year":2020,"material":"carbon","protection":"full face","weight":1.4}`, "/v1/mhelmets/1"},
+		{"Version 2", "/v2/mhelmets", `{"name":"Neotec","year":2019,"material":"fibreglass","protection":"modular","weight":1.7}`, "/v2/mhelmets/2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, body := ts.do(t, http.MethodPost, tt.urlPath, writer, tt.body)
+			if res.StatusCode != http.StatusCreated {
+				t.Fatalf("got status %d, want %d: %s", res.StatusCode, http.StatusCreated, body)
+			}
+			if location := res.Header.Get("Location"); location != tt.wantLocation {
+				t.Errorf("got Location %q, want %q", location, tt.wantLocation)
+			}
+		})
+	}
+}
+
+func TestShowMHelmet(t *testing.T) {
+	app := newTestApplication(t)
+	publisher := newTestUser(t, app, "publisher@example.com", "mhelmets:read", "mhelmets:write", "mhelmets:publish")
+	reader := newTestUser(t, app, "reader@example.com", "mhelmets:read")
+	ts := newTestServer(t, app.routes())
+
+	res, body := ts.do(t, http.MethodPost, "/v1/mhelmets", publisher, `{"name":"Published","year":2020,"material":"carbon","protection":"full face","weight":1.4,"status":"published"}`)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("creating helmet: got status %d: %s", res.StatusCode, body)
+	}
+
+	res, body = ts.do(t, http.MethodPost, "/v1/mhelmets", publisher, `{"name":"Draft","year":2021,"material":"carbon","protection":"full face","weight":1.3}`)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("creating helmet: got status %d: %s", res.StatusCode, body)
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		urlPath    string
+		wantStatus int
+		wantName   string
+	}{
+		{"Published", reader, "/v1/mhelmets/1", http.StatusOK, "Published"},
+		{"Draft for a reader", reader, "/v1/mhelmets/2", http.StatusNotFound, ""},
+		{"Draft for a writer", publisher, "/v1/mhelmets/2", http.StatusOK, "Draft"},
+		{"Non-existent ID", reader, "/v1/mhelmets/3", http.StatusNotFound, ""},
+		{"Negative ID", reader, "/v1/mhelmets/-1", http.StatusNotFound, ""},
+		{"String ID", reader, "/v1/mhelmets/foo", http.StatusNotFound, ""},
+		{"Anonymous", "", "/v1/mhelmets/1", http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, body := ts.do(t, http.MethodGet, tt.urlPath, tt.token, "")
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", res.StatusCode, tt.wantStatus, body)
+			}
+
+			if tt.wantName != "" {
+				var got struct {
+					Helmet struct {
+						Name string `json:"name"`
+					} `json:"helmet"`
+				}
+				decodeJSON(t, body, &got)
+
+				if got.Helmet.Name != tt.wantName {
+					t.Errorf("got name %q, want %q", got.Helmet.Name, tt.wantName)
+				}
+			}
+		})
+	}
+}
+
+func TestListMHelmets(t *testing.T) {
+	app := newTestApplication(t)
+	publisher := newTestUser(t, app, "publisher@example.com", "mhelmets:read", "mhelmets:write", "mhelmets:publish")
+	reader := newTestUser(t, app, "reader@example.com", "mhelmets:read")
+	ts := newTestServer(t, app.routes())
+
+	for _, body := range []string{
+		`{"name":"Light","year":2020,"material":"carbon","protection":"full face","weight":1.2,"status":"published"}`,
+		`{"name":"Heavy","year":2019,"material":"fibreglass","protection":"modular","weight":1.8,"status":"published"}`,
+		`{"name":"Unreleased","year":2021,"material":"carbon","protection":"full face","weight":1.3}`,
+	} {
+		res, resBody := ts.do(t, http.MethodPost, "/v1/mhelmets", publisher, body)
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("creating helmet: got status %d: %s", res.StatusCode, resBody)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		query      string
+		wantStatus int
+		wantNames  []string
+	}{
+		{"Reader sees published", reader, "?sort=id", http.StatusOK, []string{"Light", "Heavy"}},
+		{"Writer sees drafts", publisher, "?sort=id", http.StatusOK, []string{"Light", "Heavy", "Unreleased"}},
+		{"Material filter", reader, "?material=carbon", http.StatusOK, []string{"Light"}},
+		{"Weight filter", reader, "?weight_max=1.5", http.StatusOK, []string{"Light"}},
+		{"Sort by weight descending", reader, "?sort=-weight", http.StatusOK, []string{"Heavy", "Light"}},
+		{"Invalid sort", reader, "?sort=colour", http.StatusUnprocessableEntity, nil},
+		{"Invalid page", reader, "?page=0", http.StatusUnprocessableEntity, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, body := ts.do(t, http.MethodGet, "/v1/mhelmets"+tt.query, tt.token, "")
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", res.StatusCode, tt.wantStatus, body)
+			}
+
+			if tt.wantNames == nil {
+				return
+			}
+
+			var got struct {
+				Helmets []struct {
+					Name string `json:"name"`
+				} `json:"helmets"`
+			}
+			decodeJSON(t, body, &got)
+
+			if len(got.Helmets) != len(tt.wantNames) {
+				t.Fatalf("got %d helmets, want %d: %s", len(got.Helmets), len(tt.wantNames), body)
+			}
+			for i, helmet := range got.Helmets {
+				if helmet.Name != tt.wantNames[i] {
+					t.Errorf("helmet %d: got name %q, want %q", i, helmet.Name, tt.wantNames[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMHelmetConditionalRequests(t *testing.T) {
+	app := newTestApplication(t)
+	publisher := newTestUser(t, app, "publisher@example.com", "mhelmets:read", "mhelmets:write", "mhelmets:publish")
+	reader := newTestUser(t, app, "reader@example.com", "mhelmets:read")
+	ts := newTestServer(t, app.routes())
+
+	res, body := ts.do(t, http.MethodPost, "/v1/mhelmets", publisher, `{"name":"RPHA 11","year":2020,"material":"carbon","protection":"full face","weight":1.4,"status":"published"}`)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("creating helmet: got status %d: %s", res.StatusCode, body)
+	}
+
+	res, _ = ts.do(t, http.MethodGet, "/v1/mhelmets/1", reader, "")
+	etag := res.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("got no ETag")
+	}
+
+	res, body = ts.do(t, http.MethodGet, "/v1/mhelmets/1", reader, "", "If-None-Match", etag)
+	if res.StatusCode != http.StatusNotModified {
+		t.Fatalf("got status %d, want %d: %s", res.StatusCode, http.StatusNotModified, body)
+	}
+	if vary := res.Header.Values("Vary"); !containsString(vary, "Accept") {
+		t.Errorf("got Vary %q on a 304, want it to include Accept", vary)
+	}
+
+	res, _ = ts.do(t, http.MethodGet, "/v1/mhelmets/1", reader, "", "If-None-Match", etag, "Accept", "application/xml")
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("got status %d for another media type, want %d", res.StatusCode, http.StatusOK)
+	}
+
+	res, _ = ts.do(t, http.MethodGet, "/v2/mhelmets/1", reader, "", "If-None-Match", etag)
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("got status %d for another API version, want %d", res.StatusCode, http.StatusOK)
+	}
+
+	res, _ = ts.do(t, http.MethodGet, "/v1/mhelmets/1?fields=name", reader, "", "If-None-Match", etag)
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("got status %d for another fieldset, want %d", res.StatusCode, http.StatusOK)
+	}
+
+	// A review doesn't change the helmet version, but does change its rating.
+	res, body = ts.do(t, http.MethodPost, "/v1/mhelmets/1/reviews", reader, `{"rating":4,"title":"Comfortable","body":"Quiet at speed."}`)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("creating review: got status %d: %s", res.StatusCode, body)
+	}
+
+	res, _ = ts.do(t, http.MethodGet, "/v1/mhelmets/1", reader, "", "If-None-Match", etag)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d after a review, want %d", res.StatusCode, http.StatusOK)
+	}
+	if res.Header.Get("ETag") == etag {
+		t.Error("got the same ETag after a review")
+	}
+
+	// The representation's ETag still identifies the version for If-Match.
+	res, body = ts.do(t, http.MethodPatch, "/v1/mhelmets/1", publisher, `{"weight":1.45}`, "If-Match", res.Header.Get("ETag"))
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d for a current If-Match, want %d: %s", res.StatusCode, http.StatusOK, body)
+	}
+
+	// Write responses carry the ETag of the representation a GET returns.
+	patched := res.Header.Get("ETag")
+	res, _ = ts.do(t, http.MethodGet, "/v1/mhelmets/1", publisher, "")
+	if got := res.Header.Get("ETag"); got != patched {
+		t.Errorf("got ETag %s from GET, want %s from the PATCH response", got, patched)
+	}
+
+	res, _ = ts.do(t, http.MethodGet, "/v1/mhelmets/1", publisher, "", "If-None-Match", patched)
+	if res.StatusCode != http.StatusNotModified {
+		t.Errorf("got status %d for the PATCH response's ETag, want %d", res.StatusCode, http.StatusNotModified)
+	}
+
+	res, _ = ts.do(t, http.MethodPatch, "/v1/mhelmets/1", publisher, `{"weight":1.5}`, "If-Match", etag)
+	if res.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("got status %d for a stale If-Match, want %d", res.StatusCode, http.StatusPreconditionFailed)
+	}
+
+	res, _ = ts.do(t, http.MethodPatch, "/v1/mhelmets/1", publisher, `{"weight":1.5}`, "If-Match", `W/"2"`)
+	if res.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("got status %d for a weak If-Match, want %d", res.StatusCode, http.StatusPreconditionFailed)
+	}
+
+	res, _ = ts.do(t, http.MethodDelete, "/v1/mhelmets/1", publisher, "", "If-Match", etag)
+	if res.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("got status %d for a stale If-Match on delete, want %d", res.StatusCode, http.StatusPreconditionFailed)
+	}
+}
+
+func TestMHelmetsNotAcceptable(t *testing.T) {
+	app := newTestApplication(t)
+	publisher := newTestUser(t, app, "publisher@example.com", "mhelmets:read", "mhelmets:write", "mhelmets:publish")
+	ts := newTestServer(t, app.routes())
+
+	res, body := ts.do(t, http.MethodPost, "/v1/mhelmets", publisher, `{"name":"RPHA 11","year":2020,"material":"carbon","protection":"full face","weight":1.4,"status":"published"}`)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("creating helmet: got status %d: %s", res.StatusCode, body)
+	}
+
+	tests := []struct {
+		name            string
+		method          string
+		urlPath         string
+		body            string
+		accept          string
+		wantStatus      int
+		wantContentType string
+	}{
+		{"Create as CSV", http.MethodPost, "/v1/mhelmets", `{"name":"Neotec","year":2019,"material":"fibreglass","protection":"modular","weight":1.7}`, "text/csv", http.StatusNotAcceptable, "application/json"},
+		{"Update as CSV", http.MethodPatch, "/v1/mhelmets/1", `{"weight":1.5}`, "text/csv", http.StatusNotAcceptable, "application/json"},
+		{"Show as CSV", http.MethodGet, "/v1/mhelmets/1", "", "text/csv", http.StatusNotAcceptable, "application/json"},
+		{"Show as anything but CSV", http.MethodGet, "/v1/mhelmets/1", "", "text/csv;q=0, */*", http.StatusOK, "application/json"},
+		{"Show as CSV or XML", http.MethodGet, "/v1/mhelmets/1", "", "text/csv, application/xml;q=0.5", http.StatusOK, "application/xml"},
+		{"List as CSV", http.MethodGet, "/v1/mhelmets", "", "text/csv", http.StatusOK, "text/csv"},
+		{"List as anything but CSV", http.MethodGet, "/v1/mhelmets", "", "text/csv;q=0, */*", http.StatusOK, "application/json"},
+		{"Trash as CSV", http.MethodGet, "/v1/mhelmets/trash", "", "text/csv", http.StatusOK, "text/csv"},
+		{"List as an unsupported type", http.MethodGet, "/v1/mhelmets", "", "image/png", http.StatusNotAcceptable, "application/json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, body := ts.do(t, tt.method, tt.urlPath, publisher, tt.body, "Accept", tt.accept)
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", res.StatusCode, tt.wantStatus, body)
+			}
+			if contentType := res.Header.Get("Content-Type"); contentType != tt.wantContentType {
+				t.Errorf("got Content-Type %q, want %q", contentType, tt.wantContentType)
+			}
+		})
+	}
+
+	// Refused writes mustn't change anything.
+	res, body = ts.do(t, http.MethodGet, "/v1/mhelmets", publisher, "")
+	var got struct {
+		Helmets []struct {
+			Version int32 `json:"version"`
+		} `json:"helmets"`
+	}
+	decodeJSON(t, body, &got)
+
+	if len(got.Helmets) != 1 {
+		t.Fatalf("got %d helmets after refused writes, want 1", len(got.Helmets))
+	}
+	if got.Helmets[0].Version != 1 {
+		t.Errorf("got version %d after a refused update, want 1", got.Helmets[0].Version)
+	}
+}
+
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			if strings.TrimSpace(part) == s {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -142,13 +142,12 @@ func (app *application) requireActivatedUser(next http.HandlerFunc) http.Handler
 
 func (app *application) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
 	fn := func(w http.ResponseWriter, r *http.Request) {
-		user := app.contextGetUser(r)
-		permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+		permitted, err := app.userHasPermission(r, code)
 		if err != nil {
 			app.serverErrorResponse(w, r, err)
 			return
 		}
-		if !permissions.Include(code) {
+		if !permitted {
 			app.notPermittedResponse(w, r)
 			return
 		}
@@ -157,6 +156,20 @@ func (app *application) requirePermission(code string, next http.HandlerFunc) ht
 	return app.requireActivatedUser(fn)
 }
 
+// userHasPermission reports whether the user making the request holds the
+// permission. Anonymous users hold none.
+func (app *application) userHasPermission(r *http.Request, code string) (bool, error) {
+	user := app.contextGetUser(r)
+	if user.IsAnonymous() {
+		return false, nil
+	}
+	permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		return false, err
+	}
+	return permissions.Include(code), nil
+}
+
 func (app *application) enableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Vary", "Origin")
@@ -166,9 +179,10 @@ func (app *application) enableCORS(next http.Handler) http.Handler {
 			for i := range app.config.cors.trustedOrigins {
 				if origin == app.config.cors.trustedOrigins[i] {
 					w.Header().Set("Access-Control-Allow-Origin", origin)
+					w.Header().Set("Access-Control-Expose-Headers", "ETag, Deprecation, Sunset, Link")
 					if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
 						w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, PUT, PATCH, DELETE")
-						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, If-Match, If-None-Match")
 						w.WriteHeader(http.StatusOK)
 						return
 					}
@@ -179,3 +193,41 @@ func (app *application) enableCORS(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// apiVersion records the version of the API a route belongs to in the request
+// context. Responses from version 1 carry the Deprecation and Sunset headers,
+// and link to the same resource in the current version.
+func (app *application) apiVersion(version int, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if version == 1 {
+			successor := fmt.Sprintf("/v%d%s", currentAPIVersion, strings.TrimPrefix(r.URL.Path, "/v1"))
+			w.Header().Set("Deprecation", fmt.Sprintf("@%d", app.config.v1.deprecatedAt.Unix()))
+			w.Header().Set("Sunset", app.config.v1.sunset.UTC().Format(http.TimeFormat))
+			w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, successor))
+		}
+		next(w, app.contextSetAPIVersion(r, version))
+	}
+}
+
+// negotiateResponse sends a 406 Not Acceptable response before next runs when
+// the request's Accept header rules out every media type next can respond
+// in, so that nothing is changed for a response the client won't take. list
+// tells whether next responds with lists of records, which some media
+// types, like CSV, are limited to. The acceptable encoders are kept in the
+// request context for writeResponse.
+func (app *application) negotiateResponse(list bool, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		encoders := responseEncoders
+		if !list {
+			encoders = encoders.forSingleRecords()
+		}
+
+		negotiations := encoders.negotiate(r.Header.Get("Accept"))
+		if len(negotiations) == 0 {
+			app.notAcceptableResponse(w, r, encoders.mediaTypes()...)
+			return
+		}
+
+		next(w, app.contextSetNegotiations(r, negotiations))
+	}
+}
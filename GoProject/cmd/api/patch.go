@@ -0,0 +1,103 @@
+package main
+
+import (
+	"GoProject/internal/data"
+	"GoProject/internal/jsonpatch"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	mergePatchMediaType = "application/merge-patch+json"
+	jsonPatchMediaType  = "application/json-patch+json"
+)
+
+// acceptPatch is the Accept-Patch header sent with helmets, listing the
+// patch formats PATCH /v1/mhelmets/:id understands besides plain JSON.
+var acceptPatch = strings.Join([]string{mergePatchMediaType, jsonPatchMediaType}, ", ")
+
+// patchHelmet applies the JSON Merge Patch or JSON Patch document in the
+// request body to helmet. Patches apply to the helmet's editable fields as
+// they are recorded in its revision history, so the year is a plain number.
+// Removing a field resets it to its zero value. When the patch can't be
+// applied a response is sent and false is returned.
+func (app *application) patchHelmet(w http.ResponseWriter, r *http.Request, helmet *data.Helmet, mediaType string) bool {
+	maxBytes := 1_048_576
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(maxBytes)))
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			app.badRequestResponse(w, r, fmt.Errorf("body must not be larger than %d bytes", maxBytes))
+		} else {
+			app.badRequestResponse(w, r, err)
+		}
+		return false
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		app.badRequestResponse(w, r, errors.New("body must not be empty"))
+		return false
+	}
+
+	doc, err := json.Marshal(data.NewHelmetSnapshot(helmet))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return false
+	}
+
+	switch mediaType {
+	case mergePatchMediaType:
+		doc, err = jsonpatch.MergePatch(doc, body)
+	default:
+		var patch jsonpatch.Patch
+		patch, err = jsonpatch.DecodePatch(body)
+		if err == nil {
+			doc, err = patch.Apply(doc)
+		}
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, jsonpatch.ErrInvalidPatch):
+			app.badRequestResponse(w, r, err)
+		case errors.Is(err, jsonpatch.ErrTestFailed):
+			app.errorResponse(w, r, http.StatusConflict, err.Error())
+		default:
+			app.failedValidationResponse(w, r, map[string]string{"patch": err.Error()})
+		}
+		return false
+	}
+
+	var snapshot data.HelmetSnapshot
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&snapshot); err != nil {
+		app.failedValidationResponse(w, r, snapshotErrors(err))
+		return false
+	}
+
+	// A removed certifications member means no certifications, rather than
+	// leaving them alone as Apply does for old revisions.
+	if snapshot.Certifications == nil {
+		snapshot.Certifications = []data.Certification{}
+	}
+	snapshot.Apply(helmet)
+	return true
+}
+
+// snapshotErrors describes why a patched document couldn't be decoded into a
+// helmet snapshot, keyed by the field at fault.
+func snapshotErrors(err error) map[string]string {
+	var unmarshalTypeError *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &unmarshalTypeError) && unmarshalTypeError.Field != "":
+		return map[string]string{unmarshalTypeError.Field: "has the wrong JSON type"}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return map[string]string{field: "is not a helmet field"}
+	}
+	return map[string]string{"patch": "must result in a JSON object"}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"GoProject/internal/data"
+	"GoProject/internal/validator"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// listPricesHandler returns the price timeline of a helmet, optionally
+// limited to a single currency.
+func (app *application) listPricesHandler(w http.ResponseWriter, r *http.Request) {
+	helmet, ok := app.readLiveHelmet(w, r)
+	if !ok {
+		return
+	}
+
+	v := validator.New()
+	currency := strings.ToUpper(app.readString(r.URL.Query(), "currency", ""))
+	v.Check(currency == "" || validator.In(currency, data.SupportedCurrencies...), "currency", "must be one of "+strings.Join(data.SupportedCurrencies, ", "))
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	prices, err := app.models.Prices.GetTimeline(helmet.ID, currency)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"prices": prices}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// setPriceHandler sets the price of a helmet in a currency from
+// effective_from, which defaults to now. Setting a price for a date that
+// already has one replaces it.
+func (app *application) setPriceHandler(w http.ResponseWriter, r *http.Request) {
+	helmet, ok := app.readLiveHelmet(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Currency      string     `json:"currency"`
+		Amount        data.Money `json:"amount"`
+		EffectiveFrom *time.Time `json:"effective_from"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	price := &data.Price{
+		HelmetID:      helmet.ID,
+		Currency:      strings.ToUpper(strings.TrimSpace(input.Currency)),
+		Amount:        input.Amount,
+		EffectiveFrom: time.Now().Truncate(time.Second),
+	}
+	if input.EffectiveFrom != nil {
+		price.EffectiveFrom = input.EffectiveFrom.Truncate(time.Second)
+	}
+
+	v := validator.New()
+
+	if data.ValidatePrice(v, price); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Prices.Set(price, app.contextGetUser(r).ID)
+	if err != nil {
+		app.saveErrorResponse(w, r, err)
+		return
+	}
+
+	price.Current = !price.EffectiveFrom.After(time.Now())
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"price": price}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deletePriceHandler(w http.ResponseWriter, r *http.Request) {
+	helmet, ok := app.readLiveHelmet(w, r)
+	if !ok {
+		return
+	}
+
+	priceID, err := app.readInt64Param(r, "price")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Prices.Delete(helmet.ID, priceID, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": "price successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listPriceHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	helmet, ok := app.readLiveHelmet(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-id")
+	input.Filters.SortSafelist = []string{"id", "-id"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	changes, metadata, err := app.models.Prices.GetHistory(helmet.ID, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"changes": changes, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
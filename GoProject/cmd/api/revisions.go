@@ -0,0 +1,225 @@
+package main
+
+import (
+	"GoProject/internal/data"
+	"GoProject/internal/validator"
+	"errors"
+	"net/http"
+)
+
+func (app *application) listMHelmetRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-id")
+	input.Filters.SortSafelist = []string{"id", "-id"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	_, err = app.models.Helmets.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	revisions, metadata, err := app.models.HelmetRevisions.GetAllForHelmet(id, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"revisions": revisions, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showMHelmetRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	revision, ok := app.readRevision(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.writeResponse(w, r, http.StatusOK, envelope{"revision": revision}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// diffMHelmetRevisionsHandler compares the helmet as it was after the from
+// revision with how it was after the to revision, or with its current state
+// when to is left out.
+func (app *application) diffMHelmetRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+	from := int64(app.readInt(qs, "from", 0, v))
+	to := int64(app.readInt(qs, "to", 0, v))
+
+	v.Check(from > 0, "from", "must be provided")
+	v.Check(to >= 0, "to", "must not be negative")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	helmet, err := app.models.Helmets.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	fromRevision, err := app.models.HelmetRevisions.Get(id, from)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("from", "must be a revision of this helmet")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	target := data.NewHelmetSnapshot(helmet)
+	if to != 0 {
+		toRevision, err := app.models.HelmetRevisions.Get(id, to)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				v.AddError("to", "must be a revision of this helmet")
+				app.failedValidationResponse(w, r, v.Errors)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+		target = toRevision.After
+	}
+
+	diff := envelope{
+		"from":    from,
+		"to":      to,
+		"changes": fromRevision.After.Diff(target),
+	}
+	if to == 0 {
+		diff["to"] = "current"
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"diff": diff}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// rollbackMHelmetHandler restores the helmet to how it was after the given
+// revision. The rollback is itself recorded as a new revision.
+func (app *application) rollbackMHelmetHandler(w http.ResponseWriter, r *http.Request) {
+	revision, ok := app.readRevision(w, r)
+	if !ok {
+		return
+	}
+
+	helmet, err := app.models.Helmets.Get(revision.HelmetID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if match := r.Header.Get("If-Match"); match != "" && !app.versionMatches(match, helmet.Version) {
+		app.preconditionFailedResponse(w, r)
+		return
+	}
+
+	revision.After.Apply(helmet)
+
+	v := validator.New()
+	data.ValidateHelmet(v, helmet)
+
+	if err := app.checkManufacturer(v, helmet.ManufacturerID); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Helmets.Update(helmet, app.contextGetUser(r).ID, data.RevisionActionRollback)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.saveErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeHelmet(w, r, http.StatusOK, helmet, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readRevision looks up the revision named by the :id and :revision route
+// parameters, sending a 404 response when it doesn't exist.
+func (app *application) readRevision(w http.ResponseWriter, r *http.Request) (*data.HelmetRevision, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+	revisionID, err := app.readInt64Param(r, "revision")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	revision, err := app.models.HelmetRevisions.Get(id, revisionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+	return revision, true
+}
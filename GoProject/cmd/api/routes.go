@@ -1,28 +1,144 @@
 package main
 
 import (
+	"fmt"
 	"github.com/julienschmidt/httprouter"
 	"net/http"
 )
 
+// currentAPIVersion is the latest version of the API. Older versions are
+// deprecated.
+const currentAPIVersion = 2
+
 func (app *application) routes() http.Handler {
 	router := httprouter.New()
 
 	router.NotFound = http.HandlerFunc(app.notFoundResponse)
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
 
-	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
+	// Every route is served by each version of the API. Handlers that
+	// represent resources differently between versions check
+	// contextGetAPIVersion.
+	for version := 1; version <= currentAPIVersion; version++ {
+		api := versionRouter{app: app, router: router, version: version}
+
+		api.HandlerFunc(http.MethodGet, "/healthcheck", app.healthcheckHandler)
+
+		api.ListHandlerFunc(http.MethodGet, "/mhelmets", app.requirePermission("mhelmets:read", app.listMHelmetsHandler))
+		api.HandlerFunc(http.MethodPost, "/mhelmets", app.requirePermission("mhelmets:write", app.createMHelmetHandler))
+		api.MixedHandlerFunc(http.MethodGet, "/mhelmets/:id", app.staticSegments("id", map[string]http.HandlerFunc{
+			"trash":   app.negotiateResponse(true, app.requirePermission("mhelmets:write", app.listTrashedMHelmetsHandler)),
+			"export":  app.requirePermission("mhelmets:read", app.exportMHelmetsHandler),
+			"compare": app.negotiateResponse(true, app.requirePermission("mhelmets:read", app.compareMHelmetsHandler)),
+		}, app.negotiateResponse(false, app.requirePermission("mhelmets:read", app.showMHelmetHandler))))
+		api.HandlerFunc(http.MethodPatch, "/mhelmets/:id", app.requirePermission("mhelmets:write", app.updateMHelmetHandler))
+		api.HandlerFunc(http.MethodDelete, "/mhelmets/:id", app.requirePermission("mhelmets:write", app.deleteMHelmetHandler))
+		api.HandlerFunc(http.MethodPost, "/mhelmets/:id", app.staticSegments("id", map[string]http.HandlerFunc{
+			"import": app.requirePermission("mhelmets:write", app.importMHelmetsHandler),
+		}, app.methodNotAllowedResponse))
+		api.HandlerFunc(http.MethodPut, "/mhelmets/:id/status", app.requirePermission("mhelmets:write", app.updateMHelmetStatusHandler))
+		api.HandlerFunc(http.MethodPost, "/mhelmets/:id/restore", app.requirePermission("mhelmets:write", app.restoreMHelmetHandler))
+		api.HandlerFunc(http.MethodPost, "/mhelmets/:id/purge", app.requirePermission("mhelmets:purge", app.purgeMHelmetHandler))
+		api.ListHandlerFunc(http.MethodGet, "/mhelmets/:id/variants", app.requirePermission("mhelmets:read", app.listVariantsHandler))
+		api.HandlerFunc(http.MethodPost, "/mhelmets/:id/variants", app.requirePermission("mhelmets:write", app.createVariantHandler))
+		api.HandlerFunc(http.MethodGet, "/mhelmets/:id/variants/:variant", app.requirePermission("mhelmets:read", app.showVariantHandler))
+		api.HandlerFunc(http.MethodPatch, "/mhelmets/:id/variants/:variant", app.requirePermission("mhelmets:write", app.updateVariantHandler))
+		api.HandlerFunc(http.MethodDelete, "/mhelmets/:id/variants/:variant", app.requirePermission("mhelmets:write", app.deleteVariantHandler))
+		api.ListHandlerFunc(http.MethodGet, "/mhelmets/:id/prices", app.requirePermission("mhelmets:read", app.listPricesHandler))
+		api.HandlerFunc(http.MethodPost, "/mhelmets/:id/prices", app.requirePermission("mhelmets:write", app.setPriceHandler))
+		api.ListHandlerFunc(http.MethodGet, "/mhelmets/:id/prices/history", app.requirePermission("mhelmets:write", app.listPriceHistoryHandler))
+		api.HandlerFunc(http.MethodDelete, "/mhelmets/:id/prices/:price", app.requirePermission("mhelmets:write", app.deletePriceHandler))
+		api.ListHandlerFunc(http.MethodGet, "/mhelmets/:id/images", app.requirePermission("mhelmets:read", app.listImagesHandler))
+		api.HandlerFunc(http.MethodPost, "/mhelmets/:id/images", app.requirePermission("mhelmets:write", app.uploadImageHandler))
+		api.ListHandlerFunc(http.MethodPut, "/mhelmets/:id/images/order", app.requirePermission("mhelmets:write", app.reorderImagesHandler))
+		api.HandlerFunc(http.MethodGet, "/mhelmets/:id/images/:image", app.requirePermission("mhelmets:read", app.showImageHandler))
+		api.HandlerFunc(http.MethodDelete, "/mhelmets/:id/images/:image", app.requirePermission("mhelmets:write", app.deleteImageHandler))
+		api.ListHandlerFunc(http.MethodGet, "/mhelmets/:id/reviews", app.requirePermission("mhelmets:read", app.listReviewsHandler))
+		api.HandlerFunc(http.MethodPost, "/mhelmets/:id/reviews", app.requirePermission("mhelmets:read", app.createReviewHandler))
+		api.HandlerFunc(http.MethodGet, "/mhelmets/:id/reviews/:review", app.requirePermission("mhelmets:read", app.showReviewHandler))
+		api.HandlerFunc(http.MethodPatch, "/mhelmets/:id/reviews/:review", app.requirePermission("mhelmets:read", app.updateReviewHandler))
+		api.HandlerFunc(http.MethodDelete, "/mhelmets/:id/reviews/:review", app.requirePermission("mhelmets:read", app.deleteReviewHandler))
+		api.ListHandlerFunc(http.MethodGet, "/mhelmets/:id/revisions", app.requirePermission("mhelmets:write", app.listMHelmetRevisionsHandler))
+		api.HandlerFunc(http.MethodGet, "/mhelmets/:id/revisions/:revision", app.staticSegments("revision", map[string]http.HandlerFunc{
+			"diff": app.requirePermission("mhelmets:write", app.diffMHelmetRevisionsHandler),
+		}, app.requirePermission("mhelmets:write", app.showMHelmetRevisionHandler)))
+		api.HandlerFunc(http.MethodPost, "/mhelmets/:id/revisions/:revision/rollback", app.requirePermission("mhelmets:write", app.rollbackMHelmetHandler))
+		api.HandlerFunc(http.MethodPost, "/mhelmets/:id/change-requests", app.requireActivatedUser(app.createChangeRequestHandler))
+		api.HandlerFunc(http.MethodGet, "/mhelmets/:id/change-requests/:request", app.requireActivatedUser(app.showChangeRequestHandler))
+		api.HandlerFunc(http.MethodPost, "/mhelmets/:id/change-requests/:request/approve", app.requirePermission("mhelmets:write", app.approveChangeRequestHandler))
+		api.HandlerFunc(http.MethodPost, "/mhelmets/:id/change-requests/:request/reject", app.requirePermission("mhelmets:write", app.rejectChangeRequestHandler))
+		api.ListHandlerFunc(http.MethodGet, "/change-requests", app.requirePermission("mhelmets:write", app.listChangeRequestsHandler))
+
+		api.ListHandlerFunc(http.MethodGet, "/manufacturers", app.requirePermission("manufacturers:read", app.listManufacturersHandler))
+		api.HandlerFunc(http.MethodPost, "/manufacturers", app.requirePermission("manufacturers:write", app.createManufacturerHandler))
+		api.HandlerFunc(http.MethodGet, "/manufacturers/:id", app.requirePermission("manufacturers:read", app.showManufacturerHandler))
+		api.HandlerFunc(http.MethodPatch, "/manufacturers/:id", app.requirePermission("manufacturers:write", app.updateManufacturerHandler))
+		api.HandlerFunc(http.MethodDelete, "/manufacturers/:id", app.requirePermission("manufacturers:write", app.deleteManufacturerHandler))
+
+		api.ListHandlerFunc(http.MethodGet, "/standards", app.requirePermission("mhelmets:read", app.listStandardsHandler))
 
-	router.HandlerFunc(http.MethodGet, "/v1/mhelmets", app.requirePermission("mhelmets:read", app.listMHelmetsHandler))
-	router.HandlerFunc(http.MethodPost, "/v1/mhelmets", app.requirePermission("mhelmets:write", app.createMHelmetHandler))
-	router.HandlerFunc(http.MethodGet, "/v1/mhelmets/:id", app.requirePermission("mhelmets:read", app.showMHelmetHandler))
-	router.HandlerFunc(http.MethodPatch, "/v1/mhelmets/:id", app.requirePermission("mhelmets:write", app.updateMHelmetHandler))
-	router.HandlerFunc(http.MethodDelete, "/v1/mhelmets/:id", app.requirePermission("mhelmets:write", app.deleteMHelmetHandler))
+		api.HandlerFunc(http.MethodPost, "/users", app.registerUserHandler)
+		api.HandlerFunc(http.MethodPut, "/users/activated", app.activateUserHandler)
 
-	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
-	router.HandlerFunc(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
+		api.ListHandlerFunc(http.MethodGet, "/users/me/wishlists", app.requireActivatedUser(app.listWishlistsHandler))
+		api.HandlerFunc(http.MethodPost, "/users/me/wishlists", app.requireActivatedUser(app.createWishlistHandler))
+		api.ListHandlerFunc(http.MethodGet, "/users/me/wishlists/:wishlist", app.requireActivatedUser(app.showWishlistHandler))
+		api.HandlerFunc(http.MethodPatch, "/users/me/wishlists/:wishlist", app.requireActivatedUser(app.updateWishlistHandler))
+		api.HandlerFunc(http.MethodDelete, "/users/me/wishlists/:wishlist", app.requireActivatedUser(app.deleteWishlistHandler))
+		api.HandlerFunc(http.MethodPut, "/users/me/wishlists/:wishlist/helmets/:helmet", app.requireActivatedUser(app.addWishlistHelmetHandler))
+		api.HandlerFunc(http.MethodDelete, "/users/me/wishlists/:wishlist/helmets/:helmet", app.requireActivatedUser(app.removeWishlistHelmetHandler))
+		api.ListHandlerFunc(http.MethodGet, "/users/me/change-requests", app.requireActivatedUser(app.listOwnChangeRequestsHandler))
 
-	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
+		api.HandlerFunc(http.MethodPost, "/tokens/authentication", app.createAuthenticationTokenHandler)
+	}
+
+	// Image URLs are stored with uploads, so images stay at their version 1
+	// path.
+	router.HandlerFunc(http.MethodGet, "/v1/images/*key", app.serveImageHandler)
 
 	return app.recoverPanic(app.enableCORS(app.rateLimit(app.authenticate(router))))
 }
+
+// staticSegments routes requests whose param value is one of the keys in
+// static to the matching handler, and all others to next. httprouter doesn't
+// allow a fixed path segment in the same position as a named parameter, so
+// routes like /v1/mhelmets/trash are registered through the :id route.
+func (app *application) staticSegments(param string, static map[string]http.HandlerFunc, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := httprouter.ParamsFromContext(r.Context())
+		if handler, ok := static[params.ByName(param)]; ok {
+			handler(w, r)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// versionRouter registers routes under the path prefix of one version of the
+// API, like /v2.
+type versionRouter struct {
+	app     *application
+	router  *httprouter.Router
+	version int
+}
+
+// HandlerFunc registers a route whose responses are single records or
+// messages, which can't be written as CSV.
+func (vr versionRouter) HandlerFunc(method, path string, handler http.HandlerFunc) {
+	vr.handle(method, path, vr.app.negotiateResponse(false, handler))
+}
+
+// ListHandlerFunc registers a route whose responses are lists of records.
+func (vr versionRouter) ListHandlerFunc(method, path string, handler http.HandlerFunc) {
+	vr.handle(method, path, vr.app.negotiateResponse(true, handler))
+}
+
+// MixedHandlerFunc registers a route that serves several kinds of response
+// through staticSegments, leaving handler to negotiate for each of them.
+func (vr versionRouter) MixedHandlerFunc(method, path string, handler http.HandlerFunc) {
+	vr.handle(method, path, handler)
+}
+
+func (vr versionRouter) handle(method, path string, handler http.HandlerFunc) {
+	vr.router.HandlerFunc(method, fmt.Sprintf("/v%d%s", vr.version, path), vr.app.apiVersion(vr.version, handler))
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 )
@@ -19,6 +20,13 @@ func (app *application) serve() error {
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
 	}
+	scheduler, stopScheduler := context.WithCancel(context.Background())
+	if app.config.publisher.interval > 0 {
+		app.background(func() {
+			app.runPublishScheduler(scheduler)
+		})
+	}
+
 	shutdownError := make(chan error)
 	go func() {
 		quit := make(chan os.Signal, 1)
@@ -37,6 +45,7 @@ func (app *application) serve() error {
 			"addr": srv.Addr,
 		})
 
+		stopScheduler()
 		app.wg.Wait()
 		shutdownError <- nil
 	}()
@@ -57,3 +66,29 @@ func (app *application) serve() error {
 	})
 	return nil
 }
+
+// runPublishScheduler publishes and archives the helmets whose scheduled
+// times have passed, every publish interval until ctx is cancelled.
+func (app *application) runPublishScheduler(ctx context.Context) {
+	ticker := time.NewTicker(app.config.publisher.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			published, archived, err := app.models.Helmets.ProcessSchedules(now)
+			if err != nil {
+				app.logger.PrintError(err, nil)
+				continue
+			}
+			if published > 0 || archived > 0 {
+				app.logger.PrintInfo("processed publishing schedules", map[string]string{
+					"published": strconv.FormatInt(published, 10),
+					"archived":  strconv.FormatInt(archived, 10),
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"GoProject/internal/data"
+	"net/http"
+)
+
+// listStandardsHandler lists the safety standards helmets can be certified
+// to, with the codes used in certifications and their filters.
+func (app *application) listStandardsHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.writeResponse(w, r, http.StatusOK, envelope{"standards": data.Standards}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
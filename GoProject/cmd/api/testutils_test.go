@@ -0,0 +1,105 @@
+package main
+
+import (
+	"GoProject/internal/data"
+	"GoProject/internal/jsonlog"
+	"GoProject/internal/storage"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestApplication returns an application backed by the in-memory models,
+// so that handlers can be tested without a database.
+func newTestApplication(t *testing.T) *application {
+	store, err := storage.NewLocal(t.TempDir(), "http://localhost:4000/v1/images")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &application{
+		logger:  jsonlog.New(io.Discard, jsonlog.LevelError),
+		models:  data.NewMemoryModels(),
+		storage: store,
+	}
+	app.config.env = "testing"
+	app.config.storage.maxImageBytes = 1_048_576
+	return app
+}
+
+// newTestUser creates an activated user with the given permissions and
+// returns an authentication token for them.
+func newTestUser(t *testing.T, app *application, email string, permissions ...string) string {
+	user := &data.User{Name: "Test User", Email: email, Activated: true}
+
+	err := user.Password.Set("pa55word1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = app.models.Users.Insert(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = app.models.Permissions.AddForUser(user.ID, permissions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := app.models.Tokens.New(user.ID, time.Hour, data.ScopeAuthentication)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token.Plaintext
+}
+
+type testServer struct {
+	*httptest.Server
+}
+
+func newTestServer(t *testing.T, h http.Handler) *testServer {
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+	return &testServer{ts}
+}
+
+// do sends a request with the token as bearer credentials, unless it is
+// empty, and returns the response with its body read.
+func (ts *testServer) do(t *testing.T, method, urlPath, token, body string, headers ...string) (*http.Response, []byte) {
+	req, err := http.NewRequest(method, ts.URL+urlPath, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res, bytes.TrimSpace(resBody)
+}
+
+func decodeJSON(t *testing.T, body []byte, dst interface{}) {
+	err := json.Unmarshal(body, dst)
+	if err != nil {
+		t.Fatalf("decoding %s: %v", body, err)
+	}
+}
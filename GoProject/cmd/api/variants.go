@@ -0,0 +1,236 @@
+package main
+
+import (
+	"GoProject/internal/data"
+	"GoProject/internal/validator"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+func (app *application) listVariantsHandler(w http.ResponseWriter, r *http.Request) {
+	helmet, ok := app.readLiveHelmet(w, r)
+	if !ok {
+		return
+	}
+
+	variants, err := app.models.Variants.GetAllForHelmet(helmet.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"variants": variants}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createVariantHandler(w http.ResponseWriter, r *http.Request) {
+	helmet, ok := app.readLiveHelmet(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Size   string   `json:"size"`
+		Colour string   `json:"colour"`
+		SKU    string   `json:"sku"`
+		Weight *float64 `json:"weight"`
+		Stock  int32    `json:"stock"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	variant := &data.Variant{
+		HelmetID: helmet.ID,
+		Size:     strings.ToUpper(strings.TrimSpace(input.Size)),
+		Colour:   strings.TrimSpace(input.Colour),
+		SKU:      strings.TrimSpace(input.SKU),
+		Weight:   input.Weight,
+		Stock:    input.Stock,
+	}
+
+	v := validator.New()
+
+	if data.ValidateVariant(v, variant); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Variants.Insert(variant)
+	if err != nil {
+		app.variantErrorResponse(w, r, v, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", app.location(r, "/mhelmets/%d/variants/%d", helmet.ID, variant.ID))
+
+	err = app.writeResponse(w, r, http.StatusCreated, envelope{"variant": variant}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showVariantHandler(w http.ResponseWriter, r *http.Request) {
+	variant, ok := app.readVariant(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.writeResponse(w, r, http.StatusOK, envelope{"variant": variant}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateVariantHandler updates a variant. Sending "weight": null removes the
+// weight override, so that the variant weighs the same as its helmet.
+func (app *application) updateVariantHandler(w http.ResponseWriter, r *http.Request) {
+	variant, ok := app.readVariant(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Size   *string       `json:"size"`
+		Colour *string       `json:"colour"`
+		SKU    *string       `json:"sku"`
+		Weight optionalFloat `json:"weight"`
+		Stock  *int32        `json:"stock"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Size != nil {
+		variant.Size = strings.ToUpper(strings.TrimSpace(*input.Size))
+	}
+	if input.Colour != nil {
+		variant.Colour = strings.TrimSpace(*input.Colour)
+	}
+	if input.SKU != nil {
+		variant.SKU = strings.TrimSpace(*input.SKU)
+	}
+	if input.Weight.Set {
+		variant.Weight = input.Weight.Value
+	}
+	if input.Stock != nil {
+		variant.Stock = *input.Stock
+	}
+
+	v := validator.New()
+	if data.ValidateVariant(v, variant); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Variants.Update(variant)
+	if err != nil {
+		app.variantErrorResponse(w, r, v, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"variant": variant}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteVariantHandler(w http.ResponseWriter, r *http.Request) {
+	variant, ok := app.readVariant(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.models.Variants.Delete(variant.HelmetID, variant.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": "variant successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) variantErrorResponse(w http.ResponseWriter, r *http.Request, v *validator.Validator, err error) {
+	switch {
+	case errors.Is(err, data.ErrDuplicateSKU):
+		v.AddError("sku", "a variant with this sku already exists")
+		app.failedValidationResponse(w, r, v.Errors)
+	case errors.Is(err, data.ErrDuplicateVariant):
+		v.AddError("size", "the helmet already has a variant in this size and colour")
+		app.failedValidationResponse(w, r, v.Errors)
+	case errors.Is(err, data.ErrEditConflict):
+		app.editConflictResponse(w, r)
+	default:
+		app.saveErrorResponse(w, r, err)
+	}
+}
+
+// readLiveHelmet looks up the helmet named by the :id route parameter,
+// sending a 404 response when it doesn't exist or is in the trash.
+func (app *application) readLiveHelmet(w http.ResponseWriter, r *http.Request) (*data.Helmet, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	helmet, err := app.models.Helmets.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+	if !app.requireHelmetVisible(w, r, helmet) {
+		return nil, false
+	}
+	return helmet, true
+}
+
+// readVariant looks up the variant named by the :id and :variant route
+// parameters. Variants of helmets in the trash are treated as missing.
+func (app *application) readVariant(w http.ResponseWriter, r *http.Request) (*data.Variant, bool) {
+	helmet, ok := app.readLiveHelmet(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	variantID, err := app.readInt64Param(r, "variant")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	variant, err := app.models.Variants.Get(helmet.ID, variantID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+	return variant, true
+}
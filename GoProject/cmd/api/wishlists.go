@@ -0,0 +1,337 @@
+package main
+
+import (
+	"GoProject/internal/data"
+	"GoProject/internal/validator"
+	"errors"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strings"
+)
+
+func (app *application) listWishlistsHandler(w http.ResponseWriter, r *http.Request) {
+	wishlists, err := app.models.Wishlists.GetAllForUser(app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"wishlists": wishlists}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createWishlistHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name string `json:"name"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	wishlist := &data.Wishlist{
+		UserID: app.contextGetUser(r).ID,
+		Name:   strings.TrimSpace(input.Name),
+	}
+
+	v := validator.New()
+
+	if data.ValidateWishlist(v, wishlist); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Wishlists.Insert(wishlist)
+	if err != nil {
+		app.wishlistErrorResponse(w, r, v, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", app.location(r, "/users/me/wishlists/%d", wishlist.ID))
+
+	err = app.writeResponse(w, r, http.StatusCreated, envelope{"wishlist": wishlist}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showWishlistHandler returns a wishlist along with a page of its helmets,
+// most recently added first by default.
+func (app *application) showWishlistHandler(w http.ResponseWriter, r *http.Request) {
+	wishlist, ok := app.readWishlist(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		data.Filters
+	}
+	v := validator.New()
+	qs := r.URL.Query()
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-added_at")
+	input.Filters.SortSafelist = []string{"added_at", "-added_at"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	items, metadata, err := app.models.Wishlists.GetItems(wishlist.ID, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	helmets := make([]*data.Helmet, len(items))
+	for i, item := range items {
+		helmets[i] = item.Helmet
+	}
+	if err := app.setFavourited(r, helmets...); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"wishlist": wishlist, "items": app.wishlistItemViews(r, items), "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateWishlistHandler(w http.ResponseWriter, r *http.Request) {
+	wishlist, ok := app.readWishlist(w, r)
+	if !ok {
+		return
+	}
+
+	if wishlist.Default {
+		app.errorResponse(w, r, http.StatusConflict, "the favourites list can't be renamed")
+		return
+	}
+
+	var input struct {
+		Name *string `json:"name"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		wishlist.Name = strings.TrimSpace(*input.Name)
+	}
+
+	v := validator.New()
+	if data.ValidateWishlist(v, wishlist); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Wishlists.Update(wishlist)
+	if err != nil {
+		app.wishlistErrorResponse(w, r, v, err)
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"wishlist": wishlist}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteWishlistHandler(w http.ResponseWriter, r *http.Request) {
+	wishlist, ok := app.readWishlist(w, r)
+	if !ok {
+		return
+	}
+
+	if wishlist.Default {
+		app.errorResponse(w, r, http.StatusConflict, "the favourites list can't be deleted")
+		return
+	}
+
+	err := app.models.Wishlists.Delete(wishlist.UserID, wishlist.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": "wishlist successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// addWishlistHelmetHandler puts the helmet named by the :helmet route
+// parameter on a wishlist. It is idempotent, so adding a helmet twice is not
+// an error.
+func (app *application) addWishlistHelmetHandler(w http.ResponseWriter, r *http.Request) {
+	wishlist, ok := app.readWishlist(w, r)
+	if !ok {
+		return
+	}
+
+	helmetID, err := app.readInt64Param(r, "helmet")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	helmet, err := app.models.Helmets.Get(helmetID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if helmet.Status != data.StatusPublished {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	// The wishlist or helmet can still be deleted before the item is added.
+	err = app.models.Wishlists.AddHelmet(wishlist.ID, helmetID)
+	if err != nil {
+		var constraintErr *data.ConstraintError
+		switch {
+		case errors.As(err, &constraintErr):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": "helmet added to the wishlist"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) removeWishlistHelmetHandler(w http.ResponseWriter, r *http.Request) {
+	wishlist, ok := app.readWishlist(w, r)
+	if !ok {
+		return
+	}
+
+	helmetID, err := app.readInt64Param(r, "helmet")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Wishlists.RemoveHelmet(wishlist.ID, helmetID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeResponse(w, r, http.StatusOK, envelope{"message": "helmet removed from the wishlist"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) wishlistErrorResponse(w http.ResponseWriter, r *http.Request, v *validator.Validator, err error) {
+	switch {
+	case errors.Is(err, data.ErrDuplicateWishlist):
+		v.AddError("name", "you already have a wishlist with this name")
+		app.failedValidationResponse(w, r, v.Errors)
+	case errors.Is(err, data.ErrEditConflict):
+		app.editConflictResponse(w, r)
+	default:
+		app.saveErrorResponse(w, r, err)
+	}
+}
+
+// readWishlist looks up the current user's wishlist named by the :wishlist
+// route parameter, which is either an id or "favourites" for the default
+// list. Other users' wishlists are treated as missing.
+func (app *application) readWishlist(w http.ResponseWriter, r *http.Request) (*data.Wishlist, bool) {
+	var id int64
+	if httprouter.ParamsFromContext(r.Context()).ByName("wishlist") != "favourites" {
+		var err error
+		id, err = app.readInt64Param(r, "wishlist")
+		if err != nil {
+			app.notFoundResponse(w, r)
+			return nil, false
+		}
+	}
+
+	wishlist, err := app.models.Wishlists.Get(app.contextGetUser(r).ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+	return wishlist, true
+}
+
+// setFavourited flags which of the helmets are on the current user's
+// favourites.
+func (app *application) setFavourited(r *http.Request, helmets ...*data.Helmet) error {
+	if len(helmets) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(helmets))
+	for i, helmet := range helmets {
+		ids[i] = helmet.ID
+	}
+
+	favourited, err := app.models.Wishlists.Favourited(app.contextGetUser(r).ID, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, helmet := range helmets {
+		f := favourited[helmet.ID]
+		helmet.Favourited = &f
+	}
+	return nil
+}
+
+// wishlistItemV2 is a wishlist item with its helmet in the version 2
+// representation.
+type wishlistItemV2 struct {
+	*data.WishlistItem
+	Helmet *data.HelmetV2 `json:"helmet"`
+}
+
+// wishlistItemViews is helmetViews for wishlist items.
+func (app *application) wishlistItemViews(r *http.Request, items []*data.WishlistItem) interface{} {
+	if app.contextGetAPIVersion(r) < 2 {
+		return items
+	}
+	views := make([]wishlistItemV2, len(items))
+	for i, item := range items {
+		views[i] = wishlistItemV2{WishlistItem: item, Helmet: (*data.HelmetV2)(item.Helmet)}
+	}
+	return views
+}
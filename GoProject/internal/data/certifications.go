@@ -0,0 +1,204 @@
+package data
+
+import (
+	"GoProject/internal/validator"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Standard is a safety standard helmets can be certified to. Since is the
+// first year helmets could be certified to it, and Rank orders standards by
+// how demanding they are. Rated standards, like SHARP, grade helmets from 1
+// to 5 stars instead of passing or failing them.
+type Standard struct {
+	Code  string `json:"code"`
+	Name  string `json:"name"`
+	Since int32  `json:"since"`
+	Rank  int    `json:"-"`
+	Rated bool   `json:"rated"`
+}
+
+// Standards lists the known standards in the order certifications are kept
+// in.
+var Standards = []Standard{
+	{Code: "dot-fmvss-218", Name: "DOT FMVSS 218", Since: 1974, Rank: 1},
+	{Code: "ece-22.05", Name: "ECE 22.05", Since: 2000, Rank: 2},
+	{Code: "ece-22.06", Name: "ECE 22.06", Since: 2020, Rank: 4},
+	{Code: "snell-m2015", Name: "Snell M2015", Since: 2015, Rank: 3},
+	{Code: "snell-m2020", Name: "Snell M2020", Since: 2019, Rank: 4},
+	{Code: "fim-frhphe-01", Name: "FIM FRHPhe-01", Since: 2019, Rank: 5},
+	{Code: "sharp", Name: "SHARP", Since: 2007, Rated: true},
+}
+
+const (
+	MinSharpRating = 1
+	MaxSharpRating = 5
+)
+
+var ErrInvalidCertification = errors.New("invalid certification")
+
+// StandardCodes returns the codes of all known standards.
+func StandardCodes() []string {
+	codes := make([]string, len(Standards))
+	for i, standard := range Standards {
+		codes[i] = standard.Code
+	}
+	return codes
+}
+
+// LookupStandard returns the standard with the given code.
+func LookupStandard(code string) (Standard, bool) {
+	for _, standard := range Standards {
+		if standard.Code == code {
+			return standard, true
+		}
+	}
+	return Standard{}, false
+}
+
+func standardIndex(code string) int {
+	for i, standard := range Standards {
+		if standard.Code == code {
+			return i
+		}
+	}
+	return len(Standards)
+}
+
+// Certification is a standard a helmet is certified to. Rating is only set
+// for rated standards.
+type Certification struct {
+	Standard string `json:"standard"`
+	Rating   int16  `json:"rating,omitempty"`
+}
+
+func (c Certification) String() string {
+	if c.Rating != 0 {
+		return c.Standard + ":" + strconv.Itoa(int(c.Rating))
+	}
+	return c.Standard
+}
+
+// ParseCertifications parses certifications written as a semicolon
+// separated list, like "ece-22.06;sharp:4", which is how they are written to
+// CSV files.
+func ParseCertifications(s string) ([]Certification, error) {
+	certifications := []Certification{}
+	for _, part := range strings.Split(s, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		standard, rating, found := strings.Cut(part, ":")
+		certification := Certification{Standard: strings.ToLower(strings.TrimSpace(standard))}
+		if found {
+			r, err := strconv.ParseInt(strings.TrimSpace(rating), 10, 16)
+			if err != nil {
+				return nil, ErrInvalidCertification
+			}
+			certification.Rating = int16(r)
+		}
+		certifications = append(certifications, certification)
+	}
+	return certifications, nil
+}
+
+func FormatCertifications(certifications []Certification) string {
+	parts := make([]string, len(certifications))
+	for i, certification := range certifications {
+		parts[i] = certification.String()
+	}
+	return strings.Join(parts, ";")
+}
+
+func validateCertifications(v *validator.Validator, certifications []Certification, year int32) {
+	seen := make(map[string]bool, len(certifications))
+	for _, certification := range certifications {
+		standard, ok := LookupStandard(certification.Standard)
+		if !ok {
+			v.AddError("certifications", fmt.Sprintf("%q is not a known standard, must be one of %s", certification.Standard, strings.Join(StandardCodes(), ", ")))
+			continue
+		}
+
+		v.Check(!seen[standard.Code], "certifications", fmt.Sprintf("%s must not be listed more than once", standard.Name))
+		seen[standard.Code] = true
+
+		if year != 0 {
+			v.Check(standard.Since <= year, "certifications", fmt.Sprintf("%s did not exist until %d", standard.Name, standard.Since))
+		}
+
+		if standard.Rated {
+			v.Check(certification.Rating >= MinSharpRating && certification.Rating <= MaxSharpRating, "certifications", fmt.Sprintf("%s rating must be between %d and %d", standard.Name, MinSharpRating, MaxSharpRating))
+		} else {
+			v.Check(certification.Rating == 0, "certifications", fmt.Sprintf("%s certifications do not have a rating", standard.Name))
+		}
+	}
+}
+
+// sortCertifications puts certifications in the order of Standards, so that
+// the same set is always stored and returned the same way.
+func sortCertifications(certifications []Certification) {
+	sort.SliceStable(certifications, func(i, j int) bool {
+		return standardIndex(certifications[i].Standard) < standardIndex(certifications[j].Standard)
+	})
+}
+
+// certificationColumns splits certifications into the values of the
+// certifications and sharp_rating columns.
+func certificationColumns(certifications []Certification) ([]string, sql.NullInt16) {
+	standards := make([]string, len(certifications))
+	var rating sql.NullInt16
+	for i, certification := range certifications {
+		standards[i] = certification.Standard
+		if certification.Rating != 0 {
+			rating = sql.NullInt16{Int16: certification.Rating, Valid: true}
+		}
+	}
+	return standards, rating
+}
+
+// makeCertifications is the inverse of certificationColumns.
+func makeCertifications(standards []string, rating sql.NullInt16) []Certification {
+	certifications := make([]Certification, len(standards))
+	for i, standard := range standards {
+		certifications[i] = Certification{Standard: standard}
+		if s, ok := LookupStandard(standard); ok && s.Rated {
+			certifications[i].Rating = rating.Int16
+		}
+	}
+	return certifications
+}
+
+// hasCertification reports whether helmet is certified to the standard.
+func hasCertification(helmet *Helmet, standard string) bool {
+	for _, certification := range helmet.Certifications {
+		if certification.Standard == standard {
+			return true
+		}
+	}
+	return false
+}
+
+// CertificationStrength scores a helmet's certifications by the rank of the
+// strongest standard it meets, with its SHARP stars breaking ties.
+func CertificationStrength(certifications []Certification) int {
+	rank, stars := 0, 0
+	for _, certification := range certifications {
+		standard, ok := LookupStandard(certification.Standard)
+		if !ok {
+			continue
+		}
+		if standard.Rank > rank {
+			rank = standard.Rank
+		}
+		if standard.Rated {
+			stars = int(certification.Rating)
+		}
+	}
+	return rank*10 + stars
+}
@@ -0,0 +1,276 @@
+package data
+
+import (
+	"GoProject/internal/jsonpatch"
+	"GoProject/internal/validator"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	ChangeRequestPending  = "pending"
+	ChangeRequestApproved = "approved"
+	ChangeRequestRejected = "rejected"
+)
+
+var ChangeRequestStatuses = []string{ChangeRequestPending, ChangeRequestApproved, ChangeRequestRejected}
+
+// HelmetChangeRequest is an edit to a helmet suggested by a user who may not
+// be able to edit helmets themselves. Patch is a JSON Merge Patch of the
+// helmet's snapshot. It is applied to the helmet as it is when the request is
+// approved, so that only the fields it names are changed.
+type HelmetChangeRequest struct {
+	ID            int64                  `json:"id"`
+	HelmetID      int64                  `json:"helmet_id"`
+	UserID        int64                  `json:"user_id"`
+	CreatedAt     time.Time              `json:"created_at"`
+	BaseVersion   int32                  `json:"base_version"` // Helmet version the patch was suggested against.
+	Patch         json.RawMessage        `json:"patch"`
+	Comment       string                 `json:"comment"`
+	Status        string                 `json:"status"`
+	ReviewerID    int64                  `json:"reviewer_id,omitempty"`
+	ReviewedAt    *time.Time             `json:"reviewed_at,omitempty"`
+	ReviewComment string                 `json:"review_comment,omitempty"`
+	Version       int32                  `json:"version"`
+	Changes       map[string]FieldChange `json:"changes,omitempty"` // What the patch changes in the current helmet, only set for pending requests and approvals.
+}
+
+func ValidateChangeRequest(v *validator.Validator, request *HelmetChangeRequest) {
+	patch := bytes.TrimSpace(request.Patch)
+	v.Check(len(patch) != 0, "patch", "must be provided")
+	v.Check(len(patch) == 0 || patch[0] == '{', "patch", "must be a JSON object")
+	v.Check(len(request.Patch) <= 100_000, "patch", "must not be more than 100000 bytes long")
+	v.Check(len(request.Comment) <= 1000, "comment", "must not be more than 1000 bytes long")
+}
+
+// ValidateChangeRequestReview checks the reviewer's side of a change
+// request. Rejections have to say why.
+func ValidateChangeRequestReview(v *validator.Validator, request *HelmetChangeRequest) {
+	v.Check(request.Status != ChangeRequestRejected || strings.TrimSpace(request.ReviewComment) != "", "comment", "must be provided when rejecting a change request")
+	v.Check(len(request.ReviewComment) <= 1000, "comment", "must not be more than 1000 bytes long")
+}
+
+// Propose returns the snapshot the request's patch turns current into. Errors
+// wrapping jsonpatch.ErrInvalidPatch mean the patch isn't a JSON document;
+// other errors come from decoding the result into a snapshot.
+func (c *HelmetChangeRequest) Propose(current HelmetSnapshot) (HelmetSnapshot, error) {
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return HelmetSnapshot{}, err
+	}
+	doc, err = jsonpatch.MergePatch(doc, c.Patch)
+	if err != nil {
+		return HelmetSnapshot{}, err
+	}
+
+	var proposed HelmetSnapshot
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&proposed); err != nil {
+		return HelmetSnapshot{}, err
+	}
+
+	// As with PATCH requests, removing the certifications means the helmet
+	// has none.
+	if proposed.Certifications == nil {
+		proposed.Certifications = []Certification{}
+	}
+	return proposed, nil
+}
+
+// ChangeRequestFilter restricts change request listings. Zero values leave
+// the corresponding condition out.
+type ChangeRequestFilter struct {
+	HelmetID int64
+	UserID   int64
+	Status   string
+}
+
+func ValidateChangeRequestFilter(v *validator.Validator, f ChangeRequestFilter) {
+	v.Check(f.HelmetID >= 0, "helmet_id", "must not be negative")
+	v.Check(f.Status == "" || validator.In(f.Status, ChangeRequestStatuses...), "status", "must be one of "+strings.Join(ChangeRequestStatuses, ", "))
+}
+
+func (f ChangeRequestFilter) where(args *queryArgs) string {
+	conditions := []string{"TRUE"}
+	if f.HelmetID != 0 {
+		conditions = append(conditions, "helmet_id = "+args.add(f.HelmetID))
+	}
+	if f.UserID != 0 {
+		conditions = append(conditions, "user_id = "+args.add(f.UserID))
+	}
+	if f.Status != "" {
+		conditions = append(conditions, "status = "+args.add(f.Status))
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+func (f ChangeRequestFilter) matches(request *HelmetChangeRequest) bool {
+	return (f.HelmetID == 0 || request.HelmetID == f.HelmetID) &&
+		(f.UserID == 0 || request.UserID == f.UserID) &&
+		(f.Status == "" || request.Status == f.Status)
+}
+
+type HelmetChangeRequestModel struct {
+	DB *sql.DB
+}
+
+func (m HelmetChangeRequestModel) Insert(request *HelmetChangeRequest) error {
+	query := `
+		INSERT INTO mhelmet_change_requests (helmet_id, user_id, base_version, patch, comment)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, status, version`
+
+	args := []interface{}{request.HelmetID, request.UserID, request.BaseVersion, []byte(request.Patch), request.Comment}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&request.ID, &request.CreatedAt, &request.Status, &request.Version)
+	if err != nil {
+		return translateError(err)
+	}
+	return nil
+}
+
+func (m HelmetChangeRequestModel) GetAll(filter ChangeRequestFilter, filters Filters) ([]*HelmetChangeRequest, Metadata, error) {
+	args := queryArgs{}
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, helmet_id, user_id, created_at, base_version, patch, comment, status,
+			COALESCE(reviewer_id, 0), reviewed_at, review_comment, version
+		FROM mhelmet_change_requests
+		WHERE %s
+		ORDER BY %s %s, id ASC
+		LIMIT %s OFFSET %s`, filter.where(&args), filters.sortColumn(), filters.sortDirection(), args.add(filters.limit()), args.add(filters.offset()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	requests := []*HelmetChangeRequest{}
+
+	for rows.Next() {
+		var request HelmetChangeRequest
+		var patch []byte
+		err := rows.Scan(
+			&totalRecords,
+			&request.ID,
+			&request.HelmetID,
+			&request.UserID,
+			&request.CreatedAt,
+			&request.BaseVersion,
+			&patch,
+			&request.Comment,
+			&request.Status,
+			&request.ReviewerID,
+			&request.ReviewedAt,
+			&request.ReviewComment,
+			&request.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		request.Patch = patch
+		requests = append(requests, &request)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return requests, metadata, nil
+}
+
+func (m HelmetChangeRequestModel) Get(helmetID, id int64) (*HelmetChangeRequest, error) {
+	if helmetID < 1 || id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, helmet_id, user_id, created_at, base_version, patch, comment, status,
+			COALESCE(reviewer_id, 0), reviewed_at, review_comment, version
+		FROM mhelmet_change_requests
+		WHERE helmet_id = $1 AND id = $2`
+
+	var request HelmetChangeRequest
+	var patch []byte
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, helmetID, id).Scan(
+		&request.ID,
+		&request.HelmetID,
+		&request.UserID,
+		&request.CreatedAt,
+		&request.BaseVersion,
+		&patch,
+		&request.Comment,
+		&request.Status,
+		&request.ReviewerID,
+		&request.ReviewedAt,
+		&request.ReviewComment,
+		&request.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	request.Patch = patch
+	return &request, nil
+}
+
+// Review saves the outcome of a pending change request. ErrEditConflict is
+// returned if the request has been reviewed or changed since it was read.
+func (m HelmetChangeRequestModel) Review(request *HelmetChangeRequest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return reviewChangeRequest(ctx, m.DB, request)
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func reviewChangeRequest(ctx context.Context, db queryRower, request *HelmetChangeRequest) error {
+	query := `
+		UPDATE mhelmet_change_requests
+		SET status = $1, reviewer_id = $2, reviewed_at = NOW(), review_comment = $3, version = version + 1
+		WHERE id = $4 AND version = $5 AND status = $6
+		RETURNING reviewed_at, version`
+
+	args := []interface{}{request.Status, request.ReviewerID, request.ReviewComment, request.ID, request.Version, ChangeRequestPending}
+
+	err := db.QueryRowContext(ctx, query, args...).Scan(&request.ReviewedAt, &request.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return translateError(err)
+		}
+	}
+	return nil
+}
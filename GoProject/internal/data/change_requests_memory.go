@@ -0,0 +1,94 @@
+package data
+
+import "sort"
+
+type MemoryHelmetChangeRequestModel struct {
+	store *memoryStore
+}
+
+func (m MemoryHelmetChangeRequestModel) Insert(request *HelmetChangeRequest) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if _, ok := m.store.helmets[request.HelmetID]; !ok {
+		return foreignKeyViolation("mhelmet_change_requests", "mhelmet_change_requests_helmet_id_fkey")
+	}
+	if _, ok := m.store.users[request.UserID]; !ok {
+		return foreignKeyViolation("mhelmet_change_requests", "mhelmet_change_requests_user_id_fkey")
+	}
+
+	m.store.changeRequestsSeq++
+	request.ID = m.store.changeRequestsSeq
+	request.CreatedAt = memoryNow()
+	request.Status = ChangeRequestPending
+	request.Version = 1
+
+	stored := *request
+	stored.Changes = nil
+	m.store.changeRequests[request.ID] = stored
+	return nil
+}
+
+func (m MemoryHelmetChangeRequestModel) GetAll(filter ChangeRequestFilter, filters Filters) ([]*HelmetChangeRequest, Metadata, error) {
+	m.store.mu.RLock()
+	matched := []*HelmetChangeRequest{}
+	for _, request := range m.store.changeRequests {
+		if !filter.matches(&request) {
+			continue
+		}
+		request := request
+		matched = append(matched, &request)
+	}
+	m.store.mu.RUnlock()
+
+	// Change requests can only be sorted by id.
+	descending := filters.sortDirection() == "DESC"
+	sort.Slice(matched, func(i, j int) bool {
+		if descending {
+			return matched[i].ID > matched[j].ID
+		}
+		return matched[i].ID < matched[j].ID
+	})
+
+	start, end := pageBounds(len(matched), filters)
+	return matched[start:end], calculateMetadata(len(matched), filters.Page, filters.PageSize), nil
+}
+
+func (m MemoryHelmetChangeRequestModel) Get(helmetID, id int64) (*HelmetChangeRequest, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	request, ok := m.store.changeRequests[id]
+	if !ok || request.HelmetID != helmetID {
+		return nil, ErrRecordNotFound
+	}
+	return &request, nil
+}
+
+func (m MemoryHelmetChangeRequestModel) Review(request *HelmetChangeRequest) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	current, ok := m.store.changeRequests[request.ID]
+	if !ok || current.Version != request.Version || current.Status != ChangeRequestPending {
+		return ErrEditConflict
+	}
+	m.store.reviewChangeRequest(request)
+	return nil
+}
+
+// reviewChangeRequest saves the outcome of a request that is still pending at
+// the version it was read at. The caller must hold the store's lock.
+func (s *memoryStore) reviewChangeRequest(request *HelmetChangeRequest) {
+	current := s.changeRequests[request.ID]
+	reviewedAt := memoryNow()
+	current.Status = request.Status
+	current.ReviewerID = request.ReviewerID
+	current.ReviewedAt = &reviewedAt
+	current.ReviewComment = request.ReviewComment
+	current.Version++
+	s.changeRequests[request.ID] = current
+
+	request.ReviewedAt = current.ReviewedAt
+	request.Version = current.Version
+}
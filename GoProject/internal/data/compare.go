@@ -0,0 +1,165 @@
+package data
+
+import "reflect"
+
+const (
+	MinCompareHelmets = 2
+	MaxCompareHelmets = 5
+)
+
+// AttributeComparison lines up one attribute of the compared helmets. Values
+// are in the same order as the helmets. Best and Worst hold the ids of the
+// helmets with the best and worst value, and are only set for attributes that
+// can be ranked and differ.
+type AttributeComparison struct {
+	Attribute string        `json:"attribute"`
+	Values    []interface{} `json:"values"`
+	Differs   bool          `json:"differs"`
+	Best      []int64       `json:"best,omitempty"`
+	Worst     []int64       `json:"worst,omitempty"`
+}
+
+// comparedAttribute describes how an attribute is compared. score returns a
+// value where higher is better, and false when the helmet can't be ranked on
+// the attribute. Attributes without a score are only checked for
+// differences.
+type comparedAttribute struct {
+	name  string
+	value func(h *Helmet) interface{}
+	score func(h *Helmet) (float64, bool)
+}
+
+var comparedAttributes = []comparedAttribute{
+	{
+		name:  "manufacturer",
+		value: func(h *Helmet) interface{} { return manufacturerName(h) },
+	},
+	{
+		name:  "year",
+		value: func(h *Helmet) interface{} { return h.Year },
+		score: func(h *Helmet) (float64, bool) { return float64(h.Year), true },
+	},
+	{
+		name:  "material",
+		value: func(h *Helmet) interface{} { return h.Material },
+	},
+	{
+		name:  "protection",
+		value: func(h *Helmet) interface{} { return h.Protection },
+	},
+	{
+		name:  "certifications",
+		value: func(h *Helmet) interface{} { return FormatCertifications(h.Certifications) },
+		score: func(h *Helmet) (float64, bool) { return float64(CertificationStrength(h.Certifications)), true },
+	},
+	{
+		name:  "weight",
+		value: func(h *Helmet) interface{} { return h.Weight },
+		score: func(h *Helmet) (float64, bool) { return -h.Weight, true },
+	},
+	{
+		name:  "ventilation",
+		value: func(h *Helmet) interface{} { return h.Ventilation },
+		score: func(h *Helmet) (float64, bool) { return boolScore(h.Ventilation), true },
+	},
+	{
+		name:  "sun_protection",
+		value: func(h *Helmet) interface{} { return h.SunProtection },
+		score: func(h *Helmet) (float64, bool) { return boolScore(h.SunProtection), true },
+	},
+	{
+		name: "rating",
+		value: func(h *Helmet) interface{} {
+			if h.ReviewCount == 0 {
+				return nil
+			}
+			return h.Rating
+		},
+		score: func(h *Helmet) (float64, bool) { return h.Rating, h.ReviewCount > 0 },
+	},
+}
+
+// CompareHelmets lines up the helmets attribute by attribute. When currency
+// is set their current prices in it are compared too, cheapest first;
+// helmets without a price in it aren't ranked.
+func CompareHelmets(helmets []*Helmet, currency string) []AttributeComparison {
+	attributes := comparedAttributes
+	if currency != "" {
+		attributes = append(attributes[:len(attributes):len(attributes)], comparedAttribute{
+			name: "price",
+			value: func(h *Helmet) interface{} {
+				if price, ok := h.Prices[currency]; ok {
+					return price
+				}
+				return nil
+			},
+			score: func(h *Helmet) (float64, bool) {
+				price, ok := h.Prices[currency]
+				return -float64(price), ok
+			},
+		})
+	}
+
+	comparisons := make([]AttributeComparison, len(attributes))
+	for i, attribute := range attributes {
+		comparisons[i] = compareAttribute(helmets, attribute)
+	}
+	return comparisons
+}
+
+func compareAttribute(helmets []*Helmet, attribute comparedAttribute) AttributeComparison {
+	comparison := AttributeComparison{
+		Attribute: attribute.name,
+		Values:    make([]interface{}, len(helmets)),
+	}
+	for i, helmet := range helmets {
+		comparison.Values[i] = attribute.value(helmet)
+		if !reflect.DeepEqual(comparison.Values[i], comparison.Values[0]) {
+			comparison.Differs = true
+		}
+	}
+
+	if !comparison.Differs || attribute.score == nil {
+		return comparison
+	}
+
+	var best, worst float64
+	ranked := false
+	for _, helmet := range helmets {
+		score, ok := attribute.score(helmet)
+		if !ok {
+			continue
+		}
+		if !ranked || score > best {
+			best = score
+		}
+		if !ranked || score < worst {
+			worst = score
+		}
+		ranked = true
+	}
+
+	// When every ranked helmet scores the same, there's no best or worst.
+	if !ranked || best == worst {
+		return comparison
+	}
+
+	for _, helmet := range helmets {
+		score, ok := attribute.score(helmet)
+		switch {
+		case !ok:
+		case score == best:
+			comparison.Best = append(comparison.Best, helmet.ID)
+		case score == worst:
+			comparison.Worst = append(comparison.Worst, helmet.ID)
+		}
+	}
+	return comparison
+}
+
+func boolScore(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
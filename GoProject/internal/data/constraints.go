@@ -0,0 +1,113 @@
+package data
+
+import (
+	"errors"
+	"github.com/lib/pq"
+)
+
+// ConstraintError is returned by models when a record violates a database
+// constraint. Field and Message describe the violation in terms of the input
+// field it comes from, so that it can be reported like a validation error.
+// Violations that have their own error, like ErrDuplicateEmail, match it with
+// errors.Is.
+type ConstraintError struct {
+	Constraint string
+	Field      string
+	Message    string
+	sentinel   error
+	err        error
+}
+
+func (e *ConstraintError) Error() string {
+	return e.err.Error()
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return e.err
+}
+
+func (e *ConstraintError) Is(target error) bool {
+	return e.sentinel != nil && target == e.sentinel
+}
+
+type constraint struct {
+	field    string
+	message  string
+	sentinel error
+}
+
+// constraints maps the check, unique and foreign key constraints created by
+// the migrations to the input fields they apply to.
+var constraints = map[string]constraint{
+	"mhelmets_year_check":           {field: "year", message: "must be between 1953 and the current year"},
+	"mhelmets_weight_check":         {field: "weight", message: "must be between 0.5 and 2.5"},
+	"mhelmets_sharp_rating_check":   {field: "certifications", message: "SHARP rating must be between 1 and 5"},
+	"mhelmets_manufacturer_id_fkey": {field: "manufacturer_id", message: "must refer to an existing manufacturer"},
+	"mhelmets_status_check":         {field: "status", message: "must be one of draft, in_review, published, archived"},
+
+	"mhelmet_revisions_helmet_id_fkey": {field: "helmet_id", message: "must refer to an existing helmet"},
+	"mhelmet_revisions_user_id_fkey":   {field: "user_id", message: "must refer to an existing user"},
+
+	"users_email_key":                      {field: "email", message: "a user with this email address already exists", sentinel: ErrDuplicateEmail},
+	"users_permissions_user_id_fkey":       {field: "user_id", message: "must refer to an existing user"},
+	"users_permissions_permission_id_fkey": {field: "permissions", message: "must refer to existing permissions"},
+	"users_permissions_pkey":               {field: "permissions", message: "the user already has this permission"},
+	"tokens_user_id_fkey":                  {field: "user_id", message: "must refer to an existing user"},
+	"tokens_pkey":                          {field: "token", message: "a token with this hash already exists"},
+	"manufacturers_name_idx":               {field: "name", message: "a manufacturer with this name already exists", sentinel: ErrDuplicateManufacturer},
+
+	"mhelmet_variants_sku_idx":                   {field: "sku", message: "a variant with this sku already exists", sentinel: ErrDuplicateSKU},
+	"mhelmet_variants_helmet_id_size_colour_idx": {field: "size", message: "the helmet already has a variant in this size and colour", sentinel: ErrDuplicateVariant},
+	"mhelmet_variants_stock_check":               {field: "stock", message: "must not be negative"},
+	"mhelmet_variants_weight_check":              {field: "weight", message: "must be between 0.5 and 2.5"},
+	"mhelmet_variants_helmet_id_fkey":            {field: "helmet_id", message: "must refer to an existing helmet"},
+
+	"mhelmet_prices_amount_check":                          {field: "amount", message: "must be greater than zero"},
+	"mhelmet_prices_helmet_id_fkey":                        {field: "helmet_id", message: "must refer to an existing helmet"},
+	"mhelmet_prices_helmet_id_currency_effective_from_key": {field: "effective_from", message: "a price in this currency is already set from this date"},
+	"mhelmet_price_changes_helmet_id_fkey":                 {field: "helmet_id", message: "must refer to an existing helmet"},
+	"mhelmet_price_changes_user_id_fkey":                   {field: "user_id", message: "must refer to an existing user"},
+	"mhelmet_images_helmet_id_fkey":                        {field: "helmet_id", message: "must refer to an existing helmet"},
+
+	"mhelmet_reviews_rating_check":          {field: "rating", message: "must be between 1 and 5"},
+	"mhelmet_reviews_helmet_id_user_id_idx": {field: "helmet_id", message: "you have already reviewed this helmet", sentinel: ErrDuplicateReview},
+	"mhelmet_reviews_helmet_id_fkey":        {field: "helmet_id", message: "must refer to an existing helmet"},
+	"mhelmet_reviews_user_id_fkey":          {field: "user_id", message: "must refer to an existing user"},
+
+	"wishlists_user_id_name_idx":      {field: "name", message: "a wishlist with this name already exists", sentinel: ErrDuplicateWishlist},
+	"wishlists_user_id_default_idx":   {field: "is_default", message: "the user already has a default wishlist"},
+	"wishlists_user_id_fkey":          {field: "user_id", message: "must refer to an existing user"},
+	"wishlist_items_pkey":             {field: "helmet_id", message: "the helmet is already in the wishlist"},
+	"wishlist_items_wishlist_id_fkey": {field: "wishlist_id", message: "must refer to an existing wishlist"},
+	"wishlist_items_helmet_id_fkey":   {field: "helmet_id", message: "must refer to an existing helmet"},
+
+	"mhelmet_change_requests_status_check":     {field: "status", message: "must be one of pending, approved, rejected"},
+	"mhelmet_change_requests_helmet_id_fkey":   {field: "helmet_id", message: "must refer to an existing helmet"},
+	"mhelmet_change_requests_user_id_fkey":     {field: "user_id", message: "must refer to an existing user"},
+	"mhelmet_change_requests_reviewer_id_fkey": {field: "reviewer_id", message: "must refer to an existing user"},
+}
+
+// translateError turns violations of the constraints above into a
+// *ConstraintError. Any other error is returned unchanged.
+func translateError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	switch pqErr.Code.Name() {
+	case "check_violation", "unique_violation", "foreign_key_violation":
+		return constraintError(pqErr.Constraint, err)
+	}
+	return err
+}
+
+// constraintError wraps err in a *ConstraintError for the named constraint,
+// if it is a known one. The memory models use it to fail like the database.
+func constraintError(name string, err error) error {
+	c, ok := constraints[name]
+	if !ok {
+		return err
+	}
+	return &ConstraintError{Constraint: name, Field: c.field, Message: c.message, sentinel: c.sentinel, err: err}
+}
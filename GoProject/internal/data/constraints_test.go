@@ -0,0 +1,142 @@
+package data
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var (
+	sqlCommentRX       = regexp.MustCompile(`--[^\n]*`)
+	createTableRX      = regexp.MustCompile(`(?is)^CREATE TABLE (?:IF NOT EXISTS )?(\w+) \((.*)\)$`)
+	addConstraintRX    = regexp.MustCompile(`(?i)^ALTER TABLE (\w+) ADD CONSTRAINT (\w+)`)
+	addColumnRX        = regexp.MustCompile(`(?i)^ALTER TABLE (\w+) ADD COLUMN (?:IF NOT EXISTS )?(.*)$`)
+	createUniqueRX     = regexp.MustCompile(`(?i)^CREATE UNIQUE INDEX (?:IF NOT EXISTS )?(\w+)`)
+	namedConstraintRX  = regexp.MustCompile(`(?i)^CONSTRAINT (\w+)`)
+	tableConstraintRX  = regexp.MustCompile(`(?i)^(PRIMARY KEY|UNIQUE|FOREIGN KEY|CHECK)\b`)
+	serialPrimaryKeyRX = regexp.MustCompile(`(?i)^\w+ (big|small)?serial PRIMARY KEY`)
+)
+
+// Every constraint the migrations create has to be in the constraints map,
+// so that violating it is reported as a validation error rather than a 500,
+// and every constraint in the map has to exist.
+func TestConstraintsCoverMigrations(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "..", "migrations", "*.up.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no migrations found")
+	}
+	sort.Strings(files)
+
+	created := make(map[string]string)
+	for _, file := range files {
+		sql, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range migrationConstraints(t, string(sql)) {
+			created[name] = filepath.Base(file)
+		}
+	}
+
+	for name, file := range created {
+		if _, ok := constraints[name]; !ok {
+			t.Errorf("constraint %s created by %s is not in the constraints map", name, file)
+		}
+	}
+	for name := range constraints {
+		if _, ok := created[name]; !ok {
+			t.Errorf("constraint %s is not created by any migration", name)
+		}
+	}
+}
+
+// migrationConstraints returns the names of the constraints and unique
+// indexes a migration creates, using the names Postgres gives unnamed ones.
+// Primary keys on serial columns are left out, since their values are
+// generated and can't collide.
+func migrationConstraints(t *testing.T, sql string) []string {
+	var names []string
+
+	for _, statement := range strings.Split(sqlCommentRX.ReplaceAllString(sql, ""), ";") {
+		statement = strings.Join(strings.Fields(statement), " ")
+
+		if m := createTableRX.FindStringSubmatch(statement); m != nil {
+			table := m[1]
+			for _, element := range splitTopLevel(m[2]) {
+				switch {
+				case namedConstraintRX.MatchString(element):
+					names = append(names, namedConstraintRX.FindStringSubmatch(element)[1])
+				case strings.HasPrefix(strings.ToUpper(element), "PRIMARY KEY"):
+					names = append(names, table+"_pkey")
+				case tableConstraintRX.MatchString(element):
+					t.Errorf("unnamed table constraint in %s: %q, give it a CONSTRAINT name", table, element)
+				default:
+					names = append(names, columnConstraints(table, element)...)
+				}
+			}
+			continue
+		}
+
+		if m := addConstraintRX.FindStringSubmatch(statement); m != nil {
+			names = append(names, m[2])
+			continue
+		}
+		if m := addColumnRX.FindStringSubmatch(statement); m != nil {
+			names = append(names, columnConstraints(m[1], m[2])...)
+			continue
+		}
+		if m := createUniqueRX.FindStringSubmatch(statement); m != nil {
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// columnConstraints returns the names of the constraints in a column
+// definition.
+func columnConstraints(table, column string) []string {
+	name := strings.Fields(column)[0]
+	upper := strings.ToUpper(column)
+
+	var names []string
+	if strings.Contains(upper, " PRIMARY KEY") && !serialPrimaryKeyRX.MatchString(column) {
+		names = append(names, table+"_pkey")
+	}
+	if strings.Contains(upper, " UNIQUE") {
+		names = append(names, table+"_"+name+"_key")
+	}
+	if strings.Contains(upper, " REFERENCES ") {
+		names = append(names, table+"_"+name+"_fkey")
+	}
+	if strings.Contains(upper, " CHECK ") {
+		names = append(names, table+"_"+name+"_check")
+	}
+	return names
+}
+
+// splitTopLevel splits the body of a CREATE TABLE statement at the commas
+// that aren't inside parentheses.
+func splitTopLevel(body string) []string {
+	var elements []string
+	depth, start := 0, 0
+	for i, c := range body {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				elements = append(elements, strings.TrimSpace(body[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(elements, strings.TrimSpace(body[start:]))
+}
@@ -0,0 +1,220 @@
+package data
+
+import (
+	"GoProject/internal/validator"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var HelmetFacetSafelist = []string{"material", "protection", "ventilation", "sun_protection", "weight", "year"}
+
+// Range facets count helmets in buckets split at these bounds. Each bucket
+// includes its lower bound and excludes its upper one.
+var (
+	weightFacetBounds = []float64{1.2, 1.4, 1.6}
+	yearFacetBounds   = []float64{2000, 2010, 2015, 2020}
+)
+
+// FacetValue is the number of matching helmets sharing a value, or falling
+// in a range, of a facet.
+type FacetValue struct {
+	Value interface{} `json:"value,omitempty"`
+	Min   *float64    `json:"min,omitempty"`
+	Max   *float64    `json:"max,omitempty"`
+	Count int         `json:"count"`
+}
+
+type Facets map[string][]FacetValue
+
+func ValidateFacets(v *validator.Validator, facets []string) {
+	for _, facet := range facets {
+		v.Check(validator.In(facet, HelmetFacetSafelist...), "facets", "invalid facet value")
+	}
+	v.Check(validator.Unique(facets), "facets", "must not contain duplicate values")
+}
+
+// facetBounds returns the range bounds of a facet, or nil for facets that
+// count distinct values.
+func facetBounds(facet string) []float64 {
+	switch facet {
+	case "weight":
+		return weightFacetBounds
+	case "year":
+		return yearFacetBounds
+	}
+	return nil
+}
+
+// facetExpression returns the SQL expression a facet groups by.
+func facetExpression(facet string) string {
+	bounds := facetBounds(facet)
+	if bounds == nil {
+		return facet
+	}
+
+	values := make([]string, len(bounds))
+	for i, bound := range bounds {
+		values[i] = strconv.FormatFloat(bound, 'f', -1, 64)
+	}
+	return fmt.Sprintf("width_bucket(%s::float8, ARRAY[%s]::float8[])", facet, strings.Join(values, ", "))
+}
+
+// facetBucket is the in-memory counterpart of width_bucket.
+func facetBucket(value float64, bounds []float64) int {
+	return sort.Search(len(bounds), func(i int) bool { return bounds[i] > value })
+}
+
+// rangeFacet returns one entry per bucket, including empty ones, so clients
+// always get the same ranges.
+func rangeFacet(bounds []float64, counts map[int]int) []FacetValue {
+	values := make([]FacetValue, 0, len(bounds)+1)
+	for i := 0; i <= len(bounds); i++ {
+		value := FacetValue{Count: counts[i]}
+		if i > 0 {
+			value.Min = &bounds[i-1]
+		}
+		if i < len(bounds) {
+			value.Max = &bounds[i]
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// sortFacetValues orders values by descending count, then by value.
+func sortFacetValues(values []FacetValue) {
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return fmt.Sprint(values[i].Value) < fmt.Sprint(values[j].Value)
+	})
+}
+
+// GetFacets counts the helmets matching filter by each of the given facets.
+// All facets are computed in a single scan using grouping sets.
+func (m HelmetModel) GetFacets(filter HelmetFilter, facets []string) (Facets, error) {
+	result := make(Facets)
+	if len(facets) == 0 {
+		return result, nil
+	}
+
+	var sets, names, values []string
+	for _, facet := range facets {
+		expression := facetExpression(facet)
+		sets = append(sets, "("+expression+")")
+		names = append(names, fmt.Sprintf("WHEN GROUPING(%s) = 0 THEN '%s'", expression, facet))
+		values = append(values, fmt.Sprintf("WHEN GROUPING(%s) = 0 THEN (%s)::text", expression, expression))
+	}
+
+	args := queryArgs{}
+	query := fmt.Sprintf(`
+		SELECT CASE %s END, CASE %s END, count(*)
+		FROM mhelmets
+		WHERE %s
+		GROUP BY GROUPING SETS (%s)`,
+		strings.Join(names, " "), strings.Join(values, " "), filter.where(&args), strings.Join(sets, ", "))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	buckets := make(map[string]map[int]int)
+	for rows.Next() {
+		var facet, value string
+		var count int
+		if err := rows.Scan(&facet, &value, &count); err != nil {
+			return nil, err
+		}
+
+		switch facet {
+		case "weight", "year":
+			bucket, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, err
+			}
+			if buckets[facet] == nil {
+				buckets[facet] = make(map[int]int)
+			}
+			buckets[facet][bucket] = count
+		case "ventilation", "sun_protection":
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, err
+			}
+			result[facet] = append(result[facet], FacetValue{Value: b, Count: count})
+		default:
+			result[facet] = append(result[facet], FacetValue{Value: value, Count: count})
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, facet := range facets {
+		if bounds := facetBounds(facet); bounds != nil {
+			result[facet] = rangeFacet(bounds, buckets[facet])
+			continue
+		}
+		if result[facet] == nil {
+			result[facet] = []FacetValue{}
+		}
+		sortFacetValues(result[facet])
+	}
+
+	return result, nil
+}
+
+func (m MemoryHelmetModel) GetFacets(filter HelmetFilter, facets []string) (Facets, error) {
+	helmets := m.filter(filter)
+
+	result := make(Facets)
+	for _, facet := range facets {
+		if bounds := facetBounds(facet); bounds != nil {
+			counts := make(map[int]int)
+			for _, helmet := range helmets {
+				value := helmet.Weight
+				if facet == "year" {
+					value = float64(helmet.Year)
+				}
+				counts[facetBucket(value, bounds)]++
+			}
+			result[facet] = rangeFacet(bounds, counts)
+			continue
+		}
+
+		counts := make(map[interface{}]int)
+		for _, helmet := range helmets {
+			switch facet {
+			case "material":
+				counts[helmet.Material]++
+			case "protection":
+				counts[helmet.Protection]++
+			case "ventilation":
+				counts[helmet.Ventilation]++
+			case "sun_protection":
+				counts[helmet.SunProtection]++
+			}
+		}
+
+		values := []FacetValue{}
+		for value, count := range counts {
+			values = append(values, FacetValue{Value: value, Count: count})
+		}
+		sortFacetValues(values)
+		result[facet] = values
+	}
+
+	return result, nil
+}
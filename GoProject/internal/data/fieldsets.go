@@ -0,0 +1,228 @@
+package data
+
+import (
+	"GoProject/internal/validator"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/lib/pq"
+	"strings"
+)
+
+// Fieldset narrows records down to the fields a client asked for with
+// fields=, and names the related resources embedded in them with include=.
+// Fields is nil when every field is wanted.
+type Fieldset struct {
+	Fields          []string
+	FieldsSafelist  []string
+	Include         []string
+	IncludeSafelist []string
+}
+
+// HelmetFieldSafelist lists the helmet fields that can be picked with
+// fields=. The id is returned whichever fields are picked.
+var HelmetFieldSafelist = []string{"id", "name", "year", "material", "ventilation", "protection", "certifications", "weight", "sun_protection",
+	"manufacturer_id", "rating", "review_count", "favourited", "status", "publish_at", "unpublish_at", "version", "relevance"}
+
+// HelmetV2FieldSafelist is HelmetFieldSafelist for version 2 of the API,
+// where helmets also have a creation time.
+var HelmetV2FieldSafelist = append([]string{"created_at"}, HelmetFieldSafelist...)
+
+// HelmetIncludeSafelist lists the related resources that can be embedded in
+// helmets with include=.
+var HelmetIncludeSafelist = []string{"manufacturer", "prices", "images", "variants"}
+
+// DefaultHelmetIncludes are embedded when include= isn't given, as they were
+// before it existed.
+var DefaultHelmetIncludes = []string{"manufacturer", "prices", "images"}
+
+func ValidateFieldset(v *validator.Validator, f Fieldset) {
+	for _, field := range f.Fields {
+		v.Check(validator.In(field, f.FieldsSafelist...), "fields", "invalid field value")
+	}
+	v.Check(validator.Unique(f.Fields), "fields", "must not contain duplicate values")
+
+	for _, include := range f.Include {
+		v.Check(validator.In(include, f.IncludeSafelist...), "include", "invalid include value")
+	}
+	v.Check(validator.Unique(f.Include), "include", "must not contain duplicate values")
+}
+
+func (f Fieldset) selects(field string) bool {
+	return f.Fields == nil || validator.In(field, f.Fields...)
+}
+
+func (f Fieldset) includes(relation string) bool {
+	return validator.In(relation, f.Include...)
+}
+
+// helmetQuery returns the fieldset to read helmets with: f, plus the fields
+// its relations are looked up by and those needed to build cursors for the
+// sort column.
+func (f Fieldset) helmetQuery(sortColumn string) Fieldset {
+	q := Fieldset{Include: append([]string{}, f.Include...)}
+	if f.Fields != nil {
+		q.Fields = append([]string{}, f.Fields...)
+	}
+
+	need := func(field string) {
+		if !q.selects(field) {
+			q.Fields = append(q.Fields, field)
+		}
+	}
+	if q.includes("manufacturer") {
+		need("manufacturer_id")
+	}
+	switch sortColumn {
+	case "manufacturer":
+		need("manufacturer_id")
+		if !q.includes("manufacturer") {
+			q.Include = append(q.Include, "manufacturer")
+		}
+	case "name", "year", "material", "ventilation", "protection", "weight", "sun_protection", "rating":
+		need(sortColumn)
+	}
+	return q
+}
+
+// trimHelmets drops the relations of helmets that f doesn't include and
+// limits their JSON representation to the fields of f.
+func (f Fieldset) trimHelmets(helmets ...*Helmet) {
+	for _, helmet := range helmets {
+		if !f.includes("manufacturer") {
+			helmet.Manufacturer = nil
+		}
+		if !f.includes("prices") {
+			helmet.Prices = nil
+		}
+		if !f.includes("images") {
+			helmet.Images = nil
+		}
+		if !f.includes("variants") {
+			helmet.Variants = nil
+		}
+		helmet.fields = f.Fields
+	}
+}
+
+// helmetRelations loads each of the relations in HelmetIncludeSafelist.
+var helmetRelations = map[string]func(ctx context.Context, db *sql.DB, helmets ...*Helmet) error{
+	"manufacturer": attachManufacturers,
+	"prices":       attachPrices,
+	"images":       attachImages,
+	"variants":     attachVariants,
+}
+
+// embedHelmetFieldset loads the relations f includes, and the ratings if f
+// selects them.
+func embedHelmetFieldset(ctx context.Context, db *sql.DB, f Fieldset, helmets ...*Helmet) error {
+	for _, relation := range f.Include {
+		if err := helmetRelations[relation](ctx, db, helmets...); err != nil {
+			return err
+		}
+	}
+	if f.selects("rating") || f.selects("review_count") {
+		return attachRatings(ctx, db, helmets...)
+	}
+	return nil
+}
+
+// helmetRow is a helmet being scanned, along with the columns that are
+// converted once the row has been read.
+type helmetRow struct {
+	Helmet
+	standards   []string
+	sharpRating sql.NullInt16
+}
+
+// helmetFieldColumns lists the helmet fields stored in mhelmets columns.
+var helmetFieldColumns = []struct {
+	field   string
+	columns string
+	dest    func(row *helmetRow) []interface{}
+}{
+	{"name", "name", func(row *helmetRow) []interface{} { return []interface{}{&row.Name} }},
+	{"year", "year", func(row *helmetRow) []interface{} { return []interface{}{&row.Year} }},
+	{"material", "material", func(row *helmetRow) []interface{} { return []interface{}{&row.Material} }},
+	{"ventilation", "ventilation", func(row *helmetRow) []interface{} { return []interface{}{&row.Ventilation} }},
+	{"protection", "protection", func(row *helmetRow) []interface{} { return []interface{}{&row.Protection} }},
+	{"weight", "weight", func(row *helmetRow) []interface{} { return []interface{}{&row.Weight} }},
+	{"sun_protection", "sun_protection", func(row *helmetRow) []interface{} { return []interface{}{&row.SunProtection} }},
+	{"manufacturer_id", "COALESCE(manufacturer_id, 0)", func(row *helmetRow) []interface{} { return []interface{}{&row.ManufacturerID} }},
+	{"certifications", "certifications, sharp_rating", func(row *helmetRow) []interface{} {
+		return []interface{}{pq.Array(&row.standards), &row.sharpRating}
+	}},
+	{"publish_at", "publish_at", func(row *helmetRow) []interface{} { return []interface{}{&row.PublishAt} }},
+	{"unpublish_at", "unpublish_at", func(row *helmetRow) []interface{} { return []interface{}{&row.UnpublishAt} }},
+}
+
+// helmetColumns returns the mhelmets columns read for the fieldset. The id,
+// creation time, status and version are always read.
+func (f Fieldset) helmetColumns() string {
+	columns := []string{"id", "created_at", "status"}
+	for _, c := range helmetFieldColumns {
+		if f.selects(c.field) {
+			columns = append(columns, c.columns)
+		}
+	}
+	return strings.Join(append(columns, "version"), ", ")
+}
+
+// helmetDest returns the scan destinations in row for helmetColumns.
+func (f Fieldset) helmetDest(row *helmetRow) []interface{} {
+	dest := []interface{}{&row.ID, &row.CreatedAt, &row.Status}
+	for _, c := range helmetFieldColumns {
+		if f.selects(c.field) {
+			dest = append(dest, c.dest(row)...)
+		}
+	}
+	return append(dest, &row.Version)
+}
+
+// helmet returns the scanned helmet.
+func (row *helmetRow) helmet() *Helmet {
+	helmet := row.Helmet
+	helmet.Certifications = makeCertifications(row.standards, row.sharpRating)
+	return &helmet
+}
+
+// selectMembers returns the JSON object js with only the members keep
+// returns true for, in their original order.
+func selectMembers(js []byte, keep func(key string) bool) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(js))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := token.(string)
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		if !keep(key) {
+			continue
+		}
+
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
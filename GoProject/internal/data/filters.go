@@ -2,23 +2,61 @@ package data
 
 import (
 	"GoProject/internal/validator"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"math"
 	"strings"
 )
 
+var ErrInvalidCursor = errors.New("invalid cursor")
+
 type Filters struct {
 	Page         int
 	PageSize     int
 	Sort         string
 	SortSafelist []string
+	Cursor       string
 }
 
 type Metadata struct {
-	CurrentPage  int `json:"current_page,omitempty"`
-	PageSize     int `json:"page_size,omitempty"`
-	FirstPage    int `json:"first_page,omitempty"`
-	LastPage     int `json:"last_page,omitempty"`
-	TotalRecords int `json:"total_records,omitempty"`
+	CurrentPage  int    `json:"current_page,omitempty"`
+	PageSize     int    `json:"page_size,omitempty"`
+	FirstPage    int    `json:"first_page,omitempty"`
+	LastPage     int    `json:"last_page,omitempty"`
+	TotalRecords int    `json:"total_records,omitempty"`
+	NextCursor   string `json:"next_cursor,omitempty"`
+	PrevCursor   string `json:"prev_cursor,omitempty"`
+}
+
+// cursor marks a position in a sorted listing: the sort value and id of the
+// row at the edge of a page. Before is set on cursors that page backwards.
+// Cursors are handed to clients as opaque base64 strings.
+type cursor struct {
+	Sort   string `json:"s"`
+	Value  string `json:"v"`
+	ID     int64  `json:"id"`
+	Before bool   `json:"b,omitempty"`
+}
+
+func (c cursor) encode() string {
+	js, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	js, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(js, &c); err != nil || c.ID < 1 {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
 }
 
 func calculateMetadata(totalRecords, page, pageSize int) Metadata {
@@ -34,6 +72,39 @@ func calculateMetadata(totalRecords, page, pageSize int) Metadata {
 	}
 }
 
+// calculateCursorMetadata returns the metadata for a page fetched in cursor
+// mode. hasMore reports whether a row was found beyond the page in the
+// direction of travel; in the other direction there is always at least the
+// row the cursor came from.
+func calculateCursorMetadata(f Filters, first, last cursor, count int, hasMore bool) Metadata {
+	metadata := Metadata{PageSize: f.PageSize}
+	if count == 0 {
+		return metadata
+	}
+
+	c, _ := decodeCursor(f.Cursor)
+	if !c.Before || hasMore {
+		metadata.PrevCursor = first.encode()
+	}
+	if c.Before || hasMore {
+		metadata.NextCursor = last.encode()
+	}
+	return metadata
+}
+
+// addPageCursors lets offset-mode clients switch to cursor mode from any page.
+func (m *Metadata) addPageCursors(f Filters, first, last cursor, count int) {
+	if count == 0 {
+		return
+	}
+	if f.Page > 1 {
+		m.PrevCursor = first.encode()
+	}
+	if f.offset()+count < m.TotalRecords {
+		m.NextCursor = last.encode()
+	}
+}
+
 func (f Filters) sortColumn() string {
 	for _, safeValue := range f.SortSafelist {
 		if f.Sort == safeValue {
@@ -43,8 +114,10 @@ func (f Filters) sortColumn() string {
 	panic("unsafe sort parameter: " + f.Sort)
 }
 
+// sortDirection returns the SQL direction for the sort value. Relevance is a
+// score where higher is better, so it is always sorted in descending order.
 func (f Filters) sortDirection() string {
-	if strings.HasPrefix(f.Sort, "-") {
+	if strings.HasPrefix(f.Sort, "-") || f.Sort == "relevance" {
 		return "DESC"
 	}
 	return "ASC"
@@ -58,6 +131,51 @@ func (f Filters) offset() int {
 	return (f.Page - 1) * f.PageSize
 }
 
+func (f Filters) usesCursor() bool {
+	return f.Cursor != ""
+}
+
+// newCursor returns a cursor pointing at a row with the given id and sort
+// value, under the current sort.
+func (f Filters) newCursor(value string, id int64, before bool) cursor {
+	return cursor{Sort: f.Sort, Value: value, ID: id, Before: before}
+}
+
+// keyset returns the SQL condition selecting rows after (or, for backwards
+// cursors, before) the cursor position, and the ORDER BY clause to fetch them
+// in. The id tiebreaker is always ascending, so it flips with the direction of
+// travel but not with the sort direction. valueParam and idParam are the
+// placeholders bound to the cursor's value and id.
+func (f Filters) keyset(column, valueParam, idParam string) (string, string) {
+	c, _ := decodeCursor(f.Cursor)
+
+	direction := f.sortDirection()
+	idDirection := "ASC"
+	if c.Before {
+		direction = reverseDirection(direction)
+		idDirection = "DESC"
+	}
+
+	valueOp, idOp := ">", ">"
+	if direction == "DESC" {
+		valueOp = "<"
+	}
+	if idDirection == "DESC" {
+		idOp = "<"
+	}
+
+	condition := "(" + column + " " + valueOp + " " + valueParam + " OR (" + column + " = " + valueParam + " AND id " + idOp + " " + idParam + "))"
+	order := column + " " + direction + ", id " + idDirection
+	return condition, order
+}
+
+func reverseDirection(direction string) string {
+	if direction == "ASC" {
+		return "DESC"
+	}
+	return "ASC"
+}
+
 func ValidateFilters(v *validator.Validator, f Filters) {
 
 	v.Check(f.Page > 0, "page", "must be greater than zero")
@@ -66,4 +184,11 @@ func ValidateFilters(v *validator.Validator, f Filters) {
 	v.Check(f.PageSize <= 100, "page_size", "must be a maximum of 100")
 
 	v.Check(validator.In(f.Sort, f.SortSafelist...), "sort", "invalid sort value")
+
+	if f.usesCursor() {
+		c, err := decodeCursor(f.Cursor)
+		v.Check(err == nil, "cursor", "must be a cursor returned in the metadata of a previous page")
+		v.Check(err != nil || c.Sort == f.Sort, "cursor", "was issued for a different sort value")
+		v.Check(f.Page == 1, "page", "cannot be combined with cursor")
+	}
 }
@@ -0,0 +1,241 @@
+package data
+
+import (
+	"GoProject/internal/validator"
+	"github.com/lib/pq"
+	"strconv"
+	"strings"
+)
+
+// HelmetFilter holds the conditions a helmet listing or export is restricted
+// to. Zero values and nil pointers leave the corresponding condition out.
+type HelmetFilter struct {
+	Name           string
+	Material       string
+	Protection     string
+	Q              string
+	WeightMin      float64
+	WeightMax      float64
+	YearMin        int32
+	YearMax        int32
+	Ventilation    *bool
+	SunProtection  *bool
+	ManufacturerID int64
+	Size           string
+	InStock        *bool
+	Currency       string
+	PriceMin       Money
+	PriceMax       Money
+	// CertificationsAny keeps helmets certified to at least one of the
+	// standards, CertificationsAll those certified to every one of them.
+	CertificationsAny []string
+	CertificationsAll []string
+	Status            string
+}
+
+func ValidateHelmetFilter(v *validator.Validator, f HelmetFilter) {
+	if f.Q != "" {
+		ValidateSearchQuery(v, ParseSearchQuery(f.Q))
+	}
+
+	v.Check(f.WeightMin >= 0, "weight_min", "must not be negative")
+	v.Check(f.WeightMax >= 0, "weight_max", "must not be negative")
+	if f.WeightMin != 0 && f.WeightMax != 0 {
+		v.Check(f.WeightMin <= f.WeightMax, "weight_max", "must not be less than weight_min")
+	}
+
+	v.Check(f.YearMin >= 0, "year_min", "must not be negative")
+	v.Check(f.YearMax >= 0, "year_max", "must not be negative")
+	v.Check(f.ManufacturerID >= 0, "manufacturer_id", "must not be negative")
+	v.Check(f.Size == "" || validator.In(f.Size, VariantSizes...), "size", "must be one of "+strings.Join(VariantSizes, ", "))
+	v.Check(f.Status == "" || validator.In(f.Status, HelmetStatuses...), "status", "must be one of "+strings.Join(HelmetStatuses, ", "))
+
+	v.Check(f.Currency == "" || validator.In(f.Currency, SupportedCurrencies...), "currency", "must be one of "+strings.Join(SupportedCurrencies, ", "))
+	v.Check(f.Currency != "" || f.PriceMin == 0, "price_min", "must be used together with currency")
+	v.Check(f.Currency != "" || f.PriceMax == 0, "price_max", "must be used together with currency")
+	if f.PriceMin != 0 && f.PriceMax != 0 {
+		v.Check(f.PriceMin <= f.PriceMax, "price_max", "must not be less than price_min")
+	}
+	if f.YearMin != 0 && f.YearMax != 0 {
+		v.Check(f.YearMin <= f.YearMax, "year_max", "must not be less than year_min")
+	}
+
+	codes := StandardCodes()
+	for _, standard := range f.CertificationsAny {
+		v.Check(validator.In(standard, codes...), "certifications_any", "must only contain "+strings.Join(codes, ", "))
+	}
+	for _, standard := range f.CertificationsAll {
+		v.Check(validator.In(standard, codes...), "certifications_all", "must only contain "+strings.Join(codes, ", "))
+	}
+}
+
+// queryArgs collects the arguments of a query built at runtime and hands out
+// their placeholders.
+type queryArgs []interface{}
+
+func (a *queryArgs) add(value interface{}) string {
+	*a = append(*a, value)
+	return "$" + strconv.Itoa(len(*a))
+}
+
+// where returns the SQL conditions selecting live helmets that match the
+// filter, binding its values to args.
+func (f HelmetFilter) where(args *queryArgs) string {
+	conditions := []string{"deleted_at IS NULL"}
+
+	if f.Name != "" {
+		conditions = append(conditions, "STRPOS(LOWER(name), LOWER("+args.add(f.Name)+")) > 0")
+	}
+	if f.Material != "" {
+		conditions = append(conditions, "STRPOS(LOWER(material), LOWER("+args.add(f.Material)+")) > 0")
+	}
+	if f.Protection != "" {
+		conditions = append(conditions, "STRPOS(LOWER(protection), LOWER("+args.add(f.Protection)+")) > 0")
+	}
+
+	if search := ParseSearchQuery(f.Q); !search.IsEmpty() {
+		anyWord := args.add(search.anyWordTSQuery())
+		conditions = append(conditions, `(
+			to_tsvector('simple', name) @@ to_tsquery('simple', `+anyWord+`)
+			OR to_tsvector('simple', material) @@ to_tsquery('simple', `+anyWord+`)
+			OR to_tsvector('simple', protection) @@ to_tsquery('simple', `+anyWord+`))`)
+		conditions = append(conditions, helmetDocument+" @@ to_tsquery('simple', "+args.add(search.tsquery())+")")
+	}
+
+	if f.WeightMin != 0 {
+		conditions = append(conditions, "weight >= "+args.add(f.WeightMin))
+	}
+	if f.WeightMax != 0 {
+		conditions = append(conditions, "weight <= "+args.add(f.WeightMax))
+	}
+	if f.YearMin != 0 {
+		conditions = append(conditions, "year >= "+args.add(f.YearMin))
+	}
+	if f.YearMax != 0 {
+		conditions = append(conditions, "year <= "+args.add(f.YearMax))
+	}
+	if f.Ventilation != nil {
+		conditions = append(conditions, "ventilation = "+args.add(*f.Ventilation))
+	}
+	if f.SunProtection != nil {
+		conditions = append(conditions, "sun_protection = "+args.add(*f.SunProtection))
+	}
+	if f.ManufacturerID != 0 {
+		conditions = append(conditions, "manufacturer_id = "+args.add(f.ManufacturerID))
+	}
+	if f.Status != "" {
+		conditions = append(conditions, "status = "+args.add(f.Status))
+	}
+	if len(f.CertificationsAny) > 0 {
+		conditions = append(conditions, "certifications && "+args.add(pq.Array(f.CertificationsAny)))
+	}
+	if len(f.CertificationsAll) > 0 {
+		conditions = append(conditions, "certifications @> "+args.add(pq.Array(f.CertificationsAll)))
+	}
+
+	// Filtering by currency only keeps helmets that currently have a price in
+	// it, so that they can be sorted and filtered by that price.
+	if f.Currency != "" {
+		price := currentPrice(args.add(f.Currency))
+		conditions = append(conditions, price+" IS NOT NULL")
+		if f.PriceMin != 0 {
+			conditions = append(conditions, price+" >= "+args.add(f.PriceMin))
+		}
+		if f.PriceMax != 0 {
+			conditions = append(conditions, price+" <= "+args.add(f.PriceMax))
+		}
+	}
+
+	// Size and stock conditions apply to the same variant, so size=M with
+	// in_stock=true only matches helmets that have size M in stock. With
+	// in_stock=false they match helmets that have no such variant in stock.
+	if f.Size != "" || f.InStock != nil {
+		variant := []string{"v.helmet_id = mhelmets.id"}
+		if f.Size != "" {
+			variant = append(variant, "v.size = "+args.add(f.Size))
+		}
+		if f.InStock != nil {
+			variant = append(variant, "v.stock > 0")
+		}
+		exists := "EXISTS (SELECT 1 FROM mhelmet_variants AS v WHERE " + strings.Join(variant, " AND ") + ")"
+		if f.InStock != nil && !*f.InStock {
+			exists = "NOT " + exists
+		}
+		conditions = append(conditions, exists)
+	}
+
+	return strings.Join(conditions, "\n\t\t\tAND ")
+}
+
+// relevance returns the SQL expression ranking helmets against the search
+// query, or a constant when the filter has none.
+func (f HelmetFilter) relevance(args *queryArgs) string {
+	search := ParseSearchQuery(f.Q)
+	if search.IsEmpty() {
+		return "0"
+	}
+	return "ts_rank(" + helmetDocument + ", to_tsquery('simple', " + args.add(search.tsquery()) + "))"
+}
+
+// price returns the SQL expression for the current price of helmets in the
+// filter currency, or a constant when the filter has none.
+func (f HelmetFilter) price(args *queryArgs) string {
+	if f.Currency == "" {
+		return "0"
+	}
+	return currentPrice(args.add(f.Currency))
+}
+
+// matches is the in-memory counterpart of where. It also returns the search
+// relevance of the helmet.
+func (f HelmetFilter) matches(helmet *Helmet) (bool, float64) {
+	if helmet.DeletedAt != nil {
+		return false, 0
+	}
+	if !containsFold(helmet.Name, f.Name) || !containsFold(helmet.Material, f.Material) || !containsFold(helmet.Protection, f.Protection) {
+		return false, 0
+	}
+	if f.WeightMin != 0 && helmet.Weight < f.WeightMin {
+		return false, 0
+	}
+	if f.WeightMax != 0 && helmet.Weight > f.WeightMax {
+		return false, 0
+	}
+	if f.YearMin != 0 && helmet.Year < f.YearMin {
+		return false, 0
+	}
+	if f.YearMax != 0 && helmet.Year > f.YearMax {
+		return false, 0
+	}
+	if f.Ventilation != nil && helmet.Ventilation != *f.Ventilation {
+		return false, 0
+	}
+	if f.SunProtection != nil && helmet.SunProtection != *f.SunProtection {
+		return false, 0
+	}
+	if f.ManufacturerID != 0 && helmet.ManufacturerID != f.ManufacturerID {
+		return false, 0
+	}
+	if f.Status != "" && helmet.Status != f.Status {
+		return false, 0
+	}
+	if len(f.CertificationsAny) > 0 {
+		found := false
+		for _, standard := range f.CertificationsAny {
+			found = found || hasCertification(helmet, standard)
+		}
+		if !found {
+			return false, 0
+		}
+	}
+	for _, standard := range f.CertificationsAll {
+		if !hasCertification(helmet, standard) {
+			return false, 0
+		}
+	}
+
+	if search := ParseSearchQuery(f.Q); !search.IsEmpty() {
+		return search.match(helmet.Name, helmet.Material, helmet.Protection)
+	}
+	return true, 0
+}
@@ -0,0 +1,62 @@
+package data
+
+import (
+	"GoProject/internal/validator"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	StatusDraft     = "draft"
+	StatusInReview  = "in_review"
+	StatusPublished = "published"
+	StatusArchived  = "archived"
+)
+
+// HelmetStatuses lists the editorial statuses of helmets. Only published
+// helmets are shown to users who can't edit helmets.
+var HelmetStatuses = []string{StatusDraft, StatusInReview, StatusPublished, StatusArchived}
+
+// helmetStatusTransitions lists the statuses a helmet can be moved to from
+// each status.
+var helmetStatusTransitions = map[string][]string{
+	StatusDraft:     {StatusInReview, StatusPublished, StatusArchived},
+	StatusInReview:  {StatusDraft, StatusPublished, StatusArchived},
+	StatusPublished: {StatusDraft, StatusArchived},
+	StatusArchived:  {StatusDraft},
+}
+
+// ValidateStatusChange checks that a helmet with status from can be given
+// the status and publishing schedule of helmet. Helmets can only be scheduled
+// to be published while they aren't, and to be unpublished while they are
+// or will be.
+func ValidateStatusChange(v *validator.Validator, from string, helmet *Helmet) {
+	if !validator.In(helmet.Status, HelmetStatuses...) {
+		v.AddError("status", "must be one of "+strings.Join(HelmetStatuses, ", "))
+		return
+	}
+	if helmet.Status != from {
+		v.Check(validator.In(helmet.Status, helmetStatusTransitions[from]...), "status", fmt.Sprintf("can't change from %s to %s", from, helmet.Status))
+	}
+
+	now := time.Now()
+	if helmet.PublishAt != nil {
+		v.Check(helmet.Status == StatusDraft || helmet.Status == StatusInReview, "publish_at", "can only be set on draft and in_review helmets")
+		v.Check(helmet.PublishAt.After(now), "publish_at", "must be in the future")
+	}
+	if helmet.UnpublishAt != nil {
+		v.Check(helmet.Status == StatusPublished || helmet.PublishAt != nil, "unpublish_at", "can only be set on published helmets or together with publish_at")
+		v.Check(helmet.UnpublishAt.After(now), "unpublish_at", "must be in the future")
+		if helmet.PublishAt != nil {
+			v.Check(helmet.UnpublishAt.After(*helmet.PublishAt), "unpublish_at", "must be after publish_at")
+		}
+	}
+}
+
+// RequiresPublishPermission reports whether giving a helmet with status from
+// the status and schedule of helmet takes the mhelmets:publish permission:
+// publishing and unpublishing it, and scheduling either.
+func RequiresPublishPermission(from string, helmet *Helmet) bool {
+	return from == StatusPublished || helmet.Status == StatusPublished || helmet.PublishAt != nil || helmet.UnpublishAt != nil
+}
@@ -7,28 +7,51 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/lib/pq"
+	"strconv"
 	"time"
 )
 
 type Helmet struct {
-	ID            int64     `json:"id"`             // Unique integer ID for the helmet
-	CreatedAt     time.Time `json:"-"`              // Timestamp for when the helmet is added to our database
-	Name          string    `json:"name"`           // Helmet name
-	Year          int32     `json:"year"`           // Helmet release year
-	Material      string    `json:"material"`       // Material used in the construction of the helmet.
-	Ventilation   bool      `json:"ventilation"`    // Ventilation system in the helmet.
-	Protection    string    `json:"protection"`     // Safety certification of the helmet (e.g., "DOT", "ECE", "Snell").
-	Weight        float64   `json:"weight"`         // Weight of the helmet in kilograms.
-	SunProtection bool      `json:"sun_protection"` // Whether the helmet has an integrated sun protection visor.
+	ID             int64            `json:"id"`              // Unique integer ID for the helmet
+	CreatedAt      time.Time        `json:"-"`               // Timestamp for when the helmet is added to our database
+	Name           string           `json:"name"`            // Helmet name
+	Year           int32            `json:"year"`            // Helmet release year
+	Material       string           `json:"material"`        // Material used in the construction of the helmet.
+	Ventilation    bool             `json:"ventilation"`     // Ventilation system in the helmet.
+	Protection     string           `json:"protection"`      // Free-text description of the helmet's protection.
+	Certifications []Certification  `json:"certifications"`  // Safety standards the helmet is certified to, in the order of Standards.
+	Weight         float64          `json:"weight"`          // Weight of the helmet in kilograms.
+	SunProtection  bool             `json:"sun_protection"`  // Whether the helmet has an integrated sun protection visor.
+	ManufacturerID int64            `json:"manufacturer_id"` // Manufacturer of the helmet, 0 when unknown.
+	Manufacturer   *Manufacturer    `json:"manufacturer"`    // Embedded manufacturer details, loaded with the helmet.
+	Prices         map[string]Money `json:"prices"`          // Prices in effect now, by currency, loaded with the helmet.
+	Images         []*Image         `json:"images"`          // Photos of the helmet in display order, loaded with the helmet.
+	Variants       []*Variant       `json:"variants"`        // Sizes and colours the helmet is sold in, only loaded when included.
+	Rating         float64          `json:"rating"`          // Average review rating, 0 when the helmet has no reviews.
+	ReviewCount    int32            `json:"review_count"`    // Number of reviews of the helmet.
+	Favourited     *bool            `json:"favourited"`      // Whether the current user has favourited the helmet, nil when not looked up.
+	Status         string           `json:"status"`          // Editorial status, one of HelmetStatuses.
+	PublishAt      *time.Time       `json:"publish_at"`      // When the helmet is scheduled to be published, nil when it isn't.
+	UnpublishAt    *time.Time       `json:"unpublish_at"`    // When the helmet is scheduled to be archived, nil when it isn't.
+	Version        int32            `json:"version"`         // Incremented on every update, used for optimistic locking.
+	DeletedAt      *time.Time       `json:"-"`               // When the helmet was moved to the trash, nil for live helmets.
+	Relevance      float64          `json:"-"`               // Full-text search rank, only set by searches.
+	Price          Money            `json:"-"`               // Price in the currency a listing is filtered by, only set by such listings.
+
+	fields []string // Fields of the JSON representation, nil for all of them.
 }
 
 func ValidateHelmet(v *validator.Validator, helmet *Helmet) {
 	v.Check(helmet.Name != "", "title", "must be provided")
 	v.Check(len(helmet.Name) <= 500, "title", "must not be more than 500 bytes long")
 	v.Check(helmet.Year != 0, "year", "must be provided")
-	v.Check(helmet.Year >= 1888, "year", "must be greater than 1888")
+	v.Check(helmet.Year >= 1953, "year", "must not be before 1953")
 	v.Check(helmet.Year <= int32(time.Now().Year()), "year", "must not be in the future")
-	//	Needs to be added some checks
+	v.Check(helmet.Weight >= 0.5 && helmet.Weight <= 2.5, "weight", "must be between 0.5 and 2.5")
+	v.Check(helmet.ManufacturerID >= 0, "manufacturer_id", "must not be negative")
+	v.Check(len(helmet.Protection) <= 500, "protection", "must not be more than 500 bytes long")
+	validateCertifications(v, helmet.Certifications, helmet.Year)
 }
 
 func (h Helmet) MarshalJSON() ([]byte, error) {
@@ -37,26 +60,159 @@ func (h Helmet) MarshalJSON() ([]byte, error) {
 		year = fmt.Sprintf("%d year", h.Year)
 	}
 
+	certifications := h.Certifications
+	if certifications == nil {
+		certifications = []Certification{}
+	}
+
+	aux := struct {
+		ID             int64            `json:"id"`
+		Name           string           `json:"name"`
+		Year           string           `json:"year"`
+		Material       string           `json:"material"`
+		Ventilation    bool             `json:"ventilation"`
+		Protection     string           `json:"protection"`
+		Certifications []Certification  `json:"certifications"`
+		Weight         float64          `json:"weight"`
+		SunProtection  bool             `json:"sun_protection"`
+		ManufacturerID int64            `json:"manufacturer_id,omitempty"`
+		Manufacturer   *Manufacturer    `json:"manufacturer,omitempty"`
+		Prices         map[string]Money `json:"prices,omitempty"`
+		Images         []*Image         `json:"images,omitempty"`
+		Variants       []*Variant       `json:"variants,omitempty"`
+		Rating         float64          `json:"rating,omitempty"`
+		ReviewCount    int32            `json:"review_count"`
+		Favourited     *bool            `json:"favourited,omitempty"`
+		Status         string           `json:"status"`
+		PublishAt      *time.Time       `json:"publish_at,omitempty"`
+		UnpublishAt    *time.Time       `json:"unpublish_at,omitempty"`
+		Version        int32            `json:"version"`
+		DeletedAt      *time.Time       `json:"deleted_at,omitempty"`
+		Relevance      float64          `json:"relevance,omitempty"`
+	}{
+		ID:             h.ID,
+		Name:           h.Name,
+		Year:           year,
+		Material:       h.Material,
+		Ventilation:    h.Ventilation,
+		Protection:     h.Protection,
+		Certifications: certifications,
+		Weight:         h.Weight,
+		SunProtection:  h.SunProtection,
+		ManufacturerID: h.ManufacturerID,
+		Manufacturer:   h.Manufacturer,
+		Prices:         h.Prices,
+		Images:         h.Images,
+		Variants:       h.Variants,
+		Rating:         h.Rating,
+		ReviewCount:    h.ReviewCount,
+		Favourited:     h.Favourited,
+		Status:         h.Status,
+		PublishAt:      h.PublishAt,
+		UnpublishAt:    h.UnpublishAt,
+		Version:        h.Version,
+		DeletedAt:      h.DeletedAt,
+		Relevance:      h.Relevance,
+	}
+
+	js, err := json.Marshal(aux)
+	if err != nil {
+		return nil, err
+	}
+	return h.selectFields(js, HelmetFieldSafelist)
+}
+
+// HelmetV2 is the representation of helmets in version 2 of the API. Unlike
+// Helmet, the year is a number, the creation time is included as an RFC 3339
+// timestamp and the rating and publishing schedule are always present.
+type HelmetV2 Helmet
+
+func (h HelmetV2) MarshalJSON() ([]byte, error) {
+	certifications := h.Certifications
+	if certifications == nil {
+		certifications = []Certification{}
+	}
+
+	var manufacturerID *int64
+	if h.ManufacturerID != 0 {
+		manufacturerID = &h.ManufacturerID
+	}
+
 	aux := struct {
-		ID            int64   `json:"id"`
-		Name          string  `json:"name"`
-		Year          string  `json:"year"`
-		Material      string  `json:"material"`
-		Ventilation   bool    `json:"ventilation"`
-		Protection    string  `json:"protection"`
-		Weight        float64 `json:"weight"`
-		SunProtection bool    `json:"sun_protection"`
+		ID             int64            `json:"id"`
+		CreatedAt      time.Time        `json:"created_at"`
+		Name           string           `json:"name"`
+		Year           int32            `json:"year"`
+		Material       string           `json:"material"`
+		Ventilation    bool             `json:"ventilation"`
+		Protection     string           `json:"protection"`
+		Certifications []Certification  `json:"certifications"`
+		Weight         float64          `json:"weight"`
+		SunProtection  bool             `json:"sun_protection"`
+		ManufacturerID *int64           `json:"manufacturer_id"`
+		Manufacturer   *Manufacturer    `json:"manufacturer,omitempty"`
+		Prices         map[string]Money `json:"prices,omitempty"`
+		Images         []*Image         `json:"images,omitempty"`
+		Variants       []*Variant       `json:"variants,omitempty"`
+		Rating         float64          `json:"rating"`
+		ReviewCount    int32            `json:"review_count"`
+		Favourited     *bool            `json:"favourited,omitempty"`
+		Status         string           `json:"status"`
+		PublishAt      *time.Time       `json:"publish_at"`
+		UnpublishAt    *time.Time       `json:"unpublish_at"`
+		Version        int32            `json:"version"`
+		DeletedAt      *time.Time       `json:"deleted_at,omitempty"`
+		Relevance      float64          `json:"relevance,omitempty"`
 	}{
-		ID:            h.ID,
-		Name:          h.Name,
-		Year:          year,
-		Material:      h.Material,
-		Ventilation:   h.Ventilation,
-		Protection:    h.Protection,
-		Weight:        h.Weight,
-		SunProtection: h.SunProtection,
+		ID:             h.ID,
+		CreatedAt:      h.CreatedAt,
+		Name:           h.Name,
+		Year:           h.Year,
+		Material:       h.Material,
+		Ventilation:    h.Ventilation,
+		Protection:     h.Protection,
+		Certifications: certifications,
+		Weight:         h.Weight,
+		SunProtection:  h.SunProtection,
+		ManufacturerID: manufacturerID,
+		Manufacturer:   h.Manufacturer,
+		Prices:         h.Prices,
+		Images:         h.Images,
+		Variants:       h.Variants,
+		Rating:         h.Rating,
+		ReviewCount:    h.ReviewCount,
+		Favourited:     h.Favourited,
+		Status:         h.Status,
+		PublishAt:      h.PublishAt,
+		UnpublishAt:    h.UnpublishAt,
+		Version:        h.Version,
+		DeletedAt:      h.DeletedAt,
+		Relevance:      h.Relevance,
+	}
+
+	js, err := json.Marshal(aux)
+	if err != nil {
+		return nil, err
+	}
+	return Helmet(h).selectFields(js, HelmetV2FieldSafelist)
+}
+
+// selectFields limits js, a JSON representation of h, to the fields picked
+// with a fieldset. Members that aren't in safelist, like the id and the
+// embedded relations, are always kept.
+func (h Helmet) selectFields(js []byte, safelist []string) ([]byte, error) {
+	if h.fields == nil {
+		return js, nil
 	}
-	return json.Marshal(aux)
+	return selectMembers(js, func(key string) bool {
+		return key == "id" || !validator.In(key, safelist...) || validator.In(key, h.fields...)
+	})
+}
+
+// embedHelmets loads the related records embedded in helmet responses by
+// default.
+func embedHelmets(ctx context.Context, db *sql.DB, helmets ...*Helmet) error {
+	return embedHelmetFieldset(ctx, db, Fieldset{Include: DefaultHelmetIncludes}, helmets...)
 }
 
 type HelmetModel struct {
@@ -64,11 +220,13 @@ type HelmetModel struct {
 }
 
 func (h HelmetModel) Insert(helmet *Helmet) error {
+	sortCertifications(helmet.Certifications)
+	standards, sharpRating := certificationColumns(helmet.Certifications)
 
 	query := `
-		INSERT INTO mhelmets (name, year, material, ventilation, protection, weight, sun_protection)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id, created_at`
+		INSERT INTO mhelmets (name, year, material, ventilation, protection, weight, sun_protection, manufacturer_id, certifications, sharp_rating, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, 0), $9, $10, $11)
+		RETURNING id, created_at, version`
 
 	args := []interface{}{
 		helmet.Name,
@@ -78,28 +236,150 @@ func (h HelmetModel) Insert(helmet *Helmet) error {
 		helmet.Protection,
 		helmet.Weight,
 		helmet.SunProtection,
+		helmet.ManufacturerID,
+		pq.Array(standards),
+		sharpRating,
+		helmet.Status,
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	return h.DB.QueryRowContext(ctx, query, args...).Scan(&helmet.ID, &helmet.CreatedAt)
+	err := h.DB.QueryRowContext(ctx, query, args...).Scan(&helmet.ID, &helmet.CreatedAt, &helmet.Version)
+	if err != nil {
+		return translateError(err)
+	}
+
+	return embedHelmets(ctx, h.DB, helmet)
+}
+
+// RowError is returned by InsertMany when one of the helmets can't be saved.
+// Index is its position in the slice passed in.
+type RowError struct {
+	Index int
+	Err   error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Index, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// InsertMany inserts all helmets in a single transaction, so either every
+// helmet is saved or none are. If a helmet fails, the error is a *RowError.
+func (h HelmetModel) InsertMany(helmets []*Helmet) error {
+	query := `
+		INSERT INTO mhelmets (name, year, material, ventilation, protection, weight, sun_protection, manufacturer_id, certifications, sharp_rating, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, 0), $9, $10, $11)
+		RETURNING id, created_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for i, helmet := range helmets {
+		sortCertifications(helmet.Certifications)
+		standards, sharpRating := certificationColumns(helmet.Certifications)
+
+		args := []interface{}{
+			helmet.Name,
+			helmet.Year,
+			helmet.Material,
+			helmet.Ventilation,
+			helmet.Protection,
+			helmet.Weight,
+			helmet.SunProtection,
+			helmet.ManufacturerID,
+			pq.Array(standards),
+			sharpRating,
+			helmet.Status,
+		}
+
+		err := stmt.QueryRowContext(ctx, args...).Scan(&helmet.ID, &helmet.CreatedAt, &helmet.Version)
+		if err != nil {
+			return &RowError{Index: i, Err: translateError(err)}
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	return embedHelmets(ctx, h.DB, helmets...)
 }
 
-func (m HelmetModel) GetAll(name string, material string, protection string, filters Filters) ([]*Helmet, Metadata, error) {
+// helmetDocument is the weighted tsvector searched by GetAll. Each part uses
+// the same expression as the GIN indexes created in migration 000003.
+const helmetDocument = `(
+	setweight(to_tsvector('simple', name), 'A') ||
+	setweight(to_tsvector('simple', material), 'B') ||
+	setweight(to_tsvector('simple', protection), 'C'))`
+
+// GetAll lists the helmets matching the filter. Only the columns of the
+// fieldset are read, and only the relations it includes are embedded.
+func (m HelmetModel) GetAll(filter HelmetFilter, filters Filters, fieldset Fieldset) ([]*Helmet, Metadata, error) {
+	args := queryArgs{}
+	relevance := filter.relevance(&args)
+	price := filter.price(&args)
+	where := filter.where(&args)
+
+	column := filters.sortColumn()
+	total := "count(*) OVER()"
+	keyset := "TRUE"
+	order := fmt.Sprintf("%s %s, id ASC", column, filters.sortDirection())
+	limit, offset := filters.limit(), filters.offset()
+
+	if filters.usesCursor() {
+		c, err := decodeCursor(filters.Cursor)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		if err := setHelmetSortValue(&Helmet{}, column, c.Value); err != nil {
+			return nil, Metadata{}, err
+		}
+		// One extra row tells us whether there is another page.
+		limit, offset = filters.limit()+1, 0
+		total = "0"
+		keyset, order = filters.keyset(column, args.add(c.Value), args.add(c.ID))
+	}
+
+	read := fieldset.helmetQuery(column)
+
 	query := fmt.Sprintf(`
-		SELECT id, created_at, name, year, material, ventilation, protection, weight, sun_protection
-		FROM mhelmets
-		WHERE (STRPOS(LOWER(name), LOWER($1)) > 0 OR $1 = '')
-		AND (STRPOS(LOWER(material), LOWER($2)) > 0 OR $2 = '')
-		AND (STRPOS(LOWER(protection), LOWER($3)) > 0 OR $3 = '')
-		ORDER BY %s %s, id ASC
-		LIMIT $4 OFFSET $5`, filters.sortColumn(), filters.sortDirection())
+		SELECT %s, %s, relevance, price
+		FROM (
+			SELECT id, created_at, name, year, material, ventilation, protection, weight, sun_protection,
+				COALESCE(manufacturer_id, 0) AS manufacturer_id, certifications, sharp_rating, status, publish_at, unpublish_at, version,
+				COALESCE((SELECT m.name FROM manufacturers AS m WHERE m.id = mhelmets.manufacturer_id), '') AS manufacturer,
+				%s AS rating,
+				%s AS relevance,
+				%s AS price
+			FROM mhelmets
+			WHERE %s
+		) AS h
+		WHERE %s
+		ORDER BY %s
+		LIMIT %s OFFSET %s`, total, read.helmetColumns(), averageRating, relevance, price, where, keyset, order, args.add(limit), args.add(offset))
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	rows, err := m.DB.QueryContext(ctx, query, name, material, filters.limit(), filters.offset())
+	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, Metadata{}, err
 	}
@@ -109,10 +389,176 @@ func (m HelmetModel) GetAll(name string, material string, protection string, fil
 	totalRecords := 0
 	helmets := []*Helmet{}
 
+	for rows.Next() {
+		var row helmetRow
+		dest := append([]interface{}{&totalRecords}, read.helmetDest(&row)...)
+		if err := rows.Scan(append(dest, &row.Relevance, &row.Price)...); err != nil {
+			return nil, Metadata{}, err
+		}
+
+		helmets = append(helmets, row.helmet())
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	if err = embedHelmetFieldset(ctx, m.DB, read, helmets...); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	if filters.usesCursor() {
+		helmets, metadata := paginateHelmets(helmets, filters)
+		fieldset.trimHelmets(helmets...)
+		return helmets, metadata, nil
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	if len(helmets) > 0 {
+		metadata.addPageCursors(filters, helmetCursor(helmets[0], filters, true), helmetCursor(helmets[len(helmets)-1], filters, false), len(helmets))
+	}
+
+	fieldset.trimHelmets(helmets...)
+	return helmets, metadata, nil
+}
+
+// paginateHelmets trims a cursor-mode result fetched with one extra row to the
+// page size, restores the requested order for backwards pages and builds the
+// page metadata.
+func paginateHelmets(helmets []*Helmet, filters Filters) ([]*Helmet, Metadata) {
+	c, _ := decodeCursor(filters.Cursor)
+
+	hasMore := len(helmets) > filters.limit()
+	if hasMore {
+		helmets = helmets[:filters.limit()]
+	}
+	if c.Before {
+		for i, j := 0, len(helmets)-1; i < j; i, j = i+1, j-1 {
+			helmets[i], helmets[j] = helmets[j], helmets[i]
+		}
+	}
+
+	if len(helmets) == 0 {
+		return helmets, calculateCursorMetadata(filters, cursor{}, cursor{}, 0, hasMore)
+	}
+	first := helmetCursor(helmets[0], filters, true)
+	last := helmetCursor(helmets[len(helmets)-1], filters, false)
+	return helmets, calculateCursorMetadata(filters, first, last, len(helmets), hasMore)
+}
+
+func helmetCursor(helmet *Helmet, filters Filters, before bool) cursor {
+	return filters.newCursor(helmetSortValue(helmet, filters.sortColumn()), helmet.ID, before)
+}
+
+// helmetSortValue formats the value of a sortable column so that it can be
+// stored in a cursor and bound as a query parameter.
+func helmetSortValue(helmet *Helmet, column string) string {
+	switch column {
+	case "id":
+		return strconv.FormatInt(helmet.ID, 10)
+	case "name":
+		return helmet.Name
+	case "year":
+		return strconv.FormatInt(int64(helmet.Year), 10)
+	case "material":
+		return helmet.Material
+	case "ventilation":
+		return strconv.FormatBool(helmet.Ventilation)
+	case "protection":
+		return helmet.Protection
+	case "weight":
+		return strconv.FormatFloat(helmet.Weight, 'g', -1, 64)
+	case "sun_protection":
+		return strconv.FormatBool(helmet.SunProtection)
+	case "manufacturer":
+		return manufacturerName(helmet)
+	case "relevance":
+		return strconv.FormatFloat(helmet.Relevance, 'g', -1, 64)
+	case "price":
+		return strconv.FormatInt(int64(helmet.Price), 10)
+	case "rating":
+		return strconv.FormatFloat(helmet.Rating, 'g', -1, 64)
+	}
+	panic("unsupported sort column: " + column)
+}
+
+// manufacturerName returns the name helmets are sorted by when sorting by
+// manufacturer. Helmets without one sort as if it had an empty name.
+func manufacturerName(helmet *Helmet) string {
+	if helmet.Manufacturer == nil {
+		return ""
+	}
+	return helmet.Manufacturer.Name
+}
+
+// setHelmetSortValue is the inverse of helmetSortValue.
+func setHelmetSortValue(helmet *Helmet, column, value string) error {
+	var err error
+	switch column {
+	case "id":
+		helmet.ID, err = strconv.ParseInt(value, 10, 64)
+	case "name":
+		helmet.Name = value
+	case "year":
+		var year int64
+		year, err = strconv.ParseInt(value, 10, 32)
+		helmet.Year = int32(year)
+	case "material":
+		helmet.Material = value
+	case "ventilation":
+		helmet.Ventilation, err = strconv.ParseBool(value)
+	case "protection":
+		helmet.Protection = value
+	case "weight":
+		helmet.Weight, err = strconv.ParseFloat(value, 64)
+	case "sun_protection":
+		helmet.SunProtection, err = strconv.ParseBool(value)
+	case "manufacturer":
+		helmet.Manufacturer = &Manufacturer{Name: value}
+	case "relevance":
+		helmet.Relevance, err = strconv.ParseFloat(value, 64)
+	case "price":
+		var price int64
+		price, err = strconv.ParseInt(value, 10, 64)
+		helmet.Price = Money(price)
+	case "rating":
+		helmet.Rating, err = strconv.ParseFloat(value, 64)
+	default:
+		panic("unsupported sort column: " + column)
+	}
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+// Stream calls fn for every helmet matching the filter, in id order, reading
+// rows from the database cursor one at a time. If fn returns an error
+// iteration stops and that error is returned.
+func (h HelmetModel) Stream(filter HelmetFilter, fn func(helmet *Helmet) error) error {
+	args := queryArgs{}
+	query := fmt.Sprintf(`
+		SELECT id, created_at, name, year, material, ventilation, protection, weight, sun_protection, COALESCE(manufacturer_id, 0),
+			certifications, sharp_rating, status, publish_at, unpublish_at, version
+		FROM mhelmets
+		WHERE %s
+		ORDER BY id ASC`, filter.where(&args))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	rows, err := h.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
 	for rows.Next() {
 		var helmet Helmet
+		var standards []string
+		var sharpRating sql.NullInt16
 		err := rows.Scan(
-			&totalRecords,
 			&helmet.ID,
 			&helmet.CreatedAt,
 			&helmet.Name,
@@ -122,49 +568,51 @@ func (m HelmetModel) GetAll(name string, material string, protection string, fil
 			&helmet.Protection,
 			&helmet.Weight,
 			&helmet.SunProtection,
+			&helmet.ManufacturerID,
+			pq.Array(&standards),
+			&sharpRating,
+			&helmet.Status,
+			&helmet.PublishAt,
+			&helmet.UnpublishAt,
+			&helmet.Version,
 		)
 		if err != nil {
-			return nil, Metadata{}, err
+			return err
 		}
 
-		helmets = append(helmets, &helmet)
-	}
-
-	if err = rows.Err(); err != nil {
-		return nil, Metadata{}, err
+		helmet.Certifications = makeCertifications(standards, sharpRating)
+		if err := fn(&helmet); err != nil {
+			return err
+		}
 	}
 
-	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
-
-	return helmets, metadata, nil
+	return rows.Err()
 }
 
 func (h HelmetModel) Get(id int64) (*Helmet, error) {
+	return h.GetWithFieldset(id, Fieldset{Include: DefaultHelmetIncludes})
+}
+
+// GetWithFieldset is like Get, but only reads the columns of the fieldset
+// and only embeds the relations it includes.
+func (h HelmetModel) GetWithFieldset(id int64, fieldset Fieldset) (*Helmet, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
-	query := `
-		SELECT id, created_at, name, year, material, ventilation, protection, weight, sun_protection
+
+	read := fieldset.helmetQuery("id")
+	query := fmt.Sprintf(`
+		SELECT %s
 		FROM mhelmets
-		WHERE id = $1`
+		WHERE id = $1 AND deleted_at IS NULL`, read.helmetColumns())
 
-	var helmet Helmet
+	var row helmetRow
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 
 	defer cancel()
 
-	err := h.DB.QueryRowContext(ctx, query, id).Scan(
-		&helmet.ID,
-		&helmet.CreatedAt,
-		&helmet.Name,
-		&helmet.Year,
-		&helmet.Material,
-		&helmet.Ventilation,
-		&helmet.Protection,
-		&helmet.Weight,
-		&helmet.SunProtection,
-	)
+	err := h.DB.QueryRowContext(ctx, query, id).Scan(read.helmetDest(&row)...)
 
 	if err != nil {
 		switch {
@@ -174,15 +622,112 @@ func (h HelmetModel) Get(id int64) (*Helmet, error) {
 			return nil, err
 		}
 	}
-	return &helmet, nil
+
+	helmet := row.helmet()
+	if err = embedHelmetFieldset(ctx, h.DB, read, helmet); err != nil {
+		return nil, err
+	}
+	fieldset.trimHelmets(helmet)
+	return helmet, nil
+}
+
+// Update saves helmet if it is still at the version it was read at, and
+// records the change in the helmet's revision history as made by userID.
+func (h HelmetModel) Update(helmet *Helmet, userID int64, action string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := updateHelmet(ctx, tx, helmet, userID, action); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return embedHelmets(ctx, h.DB, helmet)
+}
+
+// ApplyChangeRequest approves request and saves helmet, with the request's
+// changes applied, in one transaction. ErrEditConflict is returned if either
+// the request or the helmet has changed since it was read.
+func (h HelmetModel) ApplyChangeRequest(helmet *Helmet, request *HelmetChangeRequest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	request.Status = ChangeRequestApproved
+	if err := reviewChangeRequest(ctx, tx, request); err != nil {
+		return err
+	}
+
+	if err := updateHelmet(ctx, tx, helmet, request.ReviewerID, RevisionActionChangeRequest); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return embedHelmets(ctx, h.DB, helmet)
 }
 
-func (h HelmetModel) Update(helmet *Helmet) error {
+// updateHelmet saves helmet in tx if it is still at the version it was read
+// at, and records the change in its revision history.
+func updateHelmet(ctx context.Context, tx *sql.Tx, helmet *Helmet, userID int64, action string) error {
+	var current Helmet
+	var currentStandards []string
+	var currentSharpRating sql.NullInt16
 	query := `
+		SELECT name, year, material, ventilation, protection, weight, sun_protection, COALESCE(manufacturer_id, 0),
+			certifications, sharp_rating
+		FROM mhelmets
+		WHERE id = $1 AND version = $2 AND deleted_at IS NULL
+		FOR UPDATE`
+
+	err := tx.QueryRowContext(ctx, query, helmet.ID, helmet.Version).Scan(
+		&current.Name,
+		&current.Year,
+		&current.Material,
+		&current.Ventilation,
+		&current.Protection,
+		&current.Weight,
+		&current.SunProtection,
+		&current.ManufacturerID,
+		pq.Array(&currentStandards),
+		&currentSharpRating,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	current.Certifications = makeCertifications(currentStandards, currentSharpRating)
+
+	sortCertifications(helmet.Certifications)
+	standards, sharpRating := certificationColumns(helmet.Certifications)
+
+	query = `
 		UPDATE mhelmets
-		SET name = $1, year = $2, material = $3, ventilation = $4, protection = $5, weight = $6, sun_protection = $7
-		WHERE id = $8
-		RETURNING id`
+		SET name = $1, year = $2, material = $3, ventilation = $4, protection = $5, weight = $6, sun_protection = $7,
+			manufacturer_id = NULLIF($8, 0), certifications = $9, sharp_rating = $10, version = version + 1
+		WHERE id = $11
+		RETURNING version`
 
 	args := []interface{}{
 		helmet.Name,
@@ -192,34 +737,316 @@ func (h HelmetModel) Update(helmet *Helmet) error {
 		helmet.Protection,
 		helmet.Weight,
 		helmet.SunProtection,
+		helmet.ManufacturerID,
+		pq.Array(standards),
+		sharpRating,
+		helmet.ID,
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&helmet.Version)
+	if err != nil {
+		return translateError(err)
+	}
+
+	revision := &HelmetRevision{
+		HelmetID: helmet.ID,
+		Version:  helmet.Version,
+		Action:   action,
+		Status:   helmet.Status,
+		UserID:   userID,
+		Before:   NewHelmetSnapshot(&current),
+		After:    NewHelmetSnapshot(helmet),
+	}
+	return insertRevision(ctx, tx, revision)
+}
+
+// UpdateStatus saves the status and publishing schedule of helmet if it is
+// still at the version it was read at, and records the change in the
+// helmet's revision history as made by userID.
+func (h HelmetModel) UpdateStatus(helmet *Helmet, userID int64) error {
+	query := `
+		UPDATE mhelmets
+		SET status = $1, publish_at = $2, unpublish_at = $3, version = version + 1
+		WHERE id = $4 AND version = $5 AND deleted_at IS NULL
+		RETURNING version`
+
+	args := []interface{}{
+		helmet.Status,
+		helmet.PublishAt,
+		helmet.UnpublishAt,
 		helmet.ID,
+		helmet.Version,
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := h.DB.QueryRowContext(ctx, query, args...).Scan(&helmet.ID)
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&helmet.Version)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
 			return ErrEditConflict
 		default:
-			return err
+			return translateError(err)
+		}
+	}
+
+	snapshot := NewHelmetSnapshot(helmet)
+	revision := &HelmetRevision{
+		HelmetID: helmet.ID,
+		Version:  helmet.Version,
+		Action:   RevisionActionStatus,
+		Status:   helmet.Status,
+		UserID:   userID,
+		Before:   snapshot,
+		After:    snapshot,
+	}
+	if err := insertRevision(ctx, tx, revision); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ProcessSchedules publishes the helmets whose publish time is at or before
+// now, then archives the published helmets whose unpublish time is, and
+// returns how many helmets were published and archived.
+func (h HelmetModel) ProcessSchedules(now time.Time) (published, archived int64, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE mhelmets
+		SET status = $1, publish_at = NULL, version = version + 1
+		WHERE publish_at <= $2 AND status IN ($3, $4) AND deleted_at IS NULL
+		RETURNING ` + scheduledHelmetColumns
+
+	published, err = updateScheduled(ctx, tx, RevisionActionScheduledPublish, query, StatusPublished, now, StatusDraft, StatusInReview)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	query = `
+		UPDATE mhelmets
+		SET status = $1, unpublish_at = NULL, version = version + 1
+		WHERE unpublish_at <= $2 AND status = $3 AND deleted_at IS NULL
+		RETURNING ` + scheduledHelmetColumns
+
+	archived, err = updateScheduled(ctx, tx, RevisionActionScheduledUnpublish, query, StatusArchived, now, StatusPublished)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return published, archived, tx.Commit()
+}
+
+// scheduledHelmetColumns are the columns updateScheduled reads from the
+// helmets a schedule update changed.
+const scheduledHelmetColumns = `id, version, status, name, year, material, ventilation, protection, weight, sun_protection,
+		COALESCE(manufacturer_id, 0), certifications, sharp_rating`
+
+// updateScheduled runs one of the updates of ProcessSchedules, and records a
+// revision without a user for each helmet it changed. It returns how many
+// helmets were changed.
+func updateScheduled(ctx context.Context, tx *sql.Tx, action string, query string, args ...interface{}) (int64, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	revisions := []*HelmetRevision{}
+
+	for rows.Next() {
+		var helmet Helmet
+		var standards []string
+		var sharpRating sql.NullInt16
+		err := rows.Scan(
+			&helmet.ID,
+			&helmet.Version,
+			&helmet.Status,
+			&helmet.Name,
+			&helmet.Year,
+			&helmet.Material,
+			&helmet.Ventilation,
+			&helmet.Protection,
+			&helmet.Weight,
+			&helmet.SunProtection,
+			&helmet.ManufacturerID,
+			pq.Array(&standards),
+			&sharpRating,
+		)
+		if err != nil {
+			return 0, err
 		}
+		helmet.Certifications = makeCertifications(standards, sharpRating)
+
+		snapshot := NewHelmetSnapshot(&helmet)
+		revisions = append(revisions, &HelmetRevision{
+			HelmetID: helmet.ID,
+			Version:  helmet.Version,
+			Action:   action,
+			Status:   helmet.Status,
+			Before:   snapshot,
+			After:    snapshot,
+		})
 	}
 
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	for _, revision := range revisions {
+		if err := insertRevision(ctx, tx, revision); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(revisions)), nil
+}
+
+// Delete moves the helmet with the given id to the trash. When version is
+// non-zero the helmet is only deleted if it is still at that version, and
+// ErrEditConflict is returned otherwise.
+func (h HelmetModel) Delete(id int64, version int32) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		UPDATE mhelmets
+		SET deleted_at = NOW(), version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := h.DB.ExecContext(ctx, query, id, version)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		if version != 0 {
+			return ErrEditConflict
+		}
+		return ErrRecordNotFound
+	}
 	return nil
 }
 
-func (h HelmetModel) Delete(id int64) error {
+func (h HelmetModel) GetAllDeleted(filters Filters) ([]*Helmet, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, created_at, name, year, material, ventilation, protection, weight, sun_protection, COALESCE(manufacturer_id, 0),
+			certifications, sharp_rating, status, publish_at, unpublish_at, version, deleted_at
+		FROM mhelmets
+		WHERE deleted_at IS NOT NULL
+		ORDER BY %s %s, id ASC
+		LIMIT $1 OFFSET $2`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := h.DB.QueryContext(ctx, query, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	helmets := []*Helmet{}
+
+	for rows.Next() {
+		var helmet Helmet
+		var standards []string
+		var sharpRating sql.NullInt16
+		err := rows.Scan(
+			&totalRecords,
+			&helmet.ID,
+			&helmet.CreatedAt,
+			&helmet.Name,
+			&helmet.Year,
+			&helmet.Material,
+			&helmet.Ventilation,
+			&helmet.Protection,
+			&helmet.Weight,
+			&helmet.SunProtection,
+			&helmet.ManufacturerID,
+			pq.Array(&standards),
+			&sharpRating,
+			&helmet.Status,
+			&helmet.PublishAt,
+			&helmet.UnpublishAt,
+			&helmet.Version,
+			&helmet.DeletedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		helmet.Certifications = makeCertifications(standards, sharpRating)
+		helmets = append(helmets, &helmet)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	if err = embedHelmets(ctx, h.DB, helmets...); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return helmets, metadata, nil
+}
+
+func (h HelmetModel) Restore(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		UPDATE mhelmets
+		SET deleted_at = NULL, version = version + 1
+		WHERE id = $1 AND deleted_at IS NOT NULL`
+
+	return h.execTrashed(query, id)
+}
+
+// Purge permanently removes a helmet. Only helmets that are already in the
+// trash can be purged.
+func (h HelmetModel) Purge(id int64) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
 
 	query := `
 		DELETE FROM mhelmets
-		WHERE id = $1`
+		WHERE id = $1 AND deleted_at IS NOT NULL`
 
+	return h.execTrashed(query, id)
+}
+
+func (h HelmetModel) execTrashed(query string, id int64) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
@@ -238,23 +1065,3 @@ func (h HelmetModel) Delete(id int64) error {
 	}
 	return nil
 }
-
-//type MockHelmetModel struct{}
-//
-//func (h MockHelmetModel) Insert(helmet *Helmet) error {
-//	return nil
-//}
-//
-//func (h MockHelmetModel) GetAll(id int64) (*Helmet, error) {
-//	return nil, nil
-//}
-//
-//func (h MockHelmetModel) Get(id int64) (*Helmet, error) {
-//	return nil, nil
-//}
-//func (h MockHelmetModel) Update(helmet *Helmet) error {
-//	return nil
-//}
-//func (h MockHelmetModel) Delete(id int64) error {
-//	return nil
-//}
@@ -0,0 +1,575 @@
+package data
+
+import (
+	"GoProject/internal/validator"
+	"sort"
+	"strings"
+	"time"
+)
+
+type MemoryHelmetModel struct {
+	store *memoryStore
+}
+
+func (m MemoryHelmetModel) Insert(helmet *Helmet) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if err := m.store.checkHelmet(helmet); err != nil {
+		return err
+	}
+
+	m.store.helmetsSeq++
+	helmet.ID = m.store.helmetsSeq
+	helmet.CreatedAt = memoryNow()
+	helmet.Version = 1
+	m.store.storeHelmet(helmet)
+	m.store.embedHelmet(helmet)
+	return nil
+}
+
+func (m MemoryHelmetModel) InsertMany(helmets []*Helmet) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	for i, helmet := range helmets {
+		if err := m.store.checkHelmet(helmet); err != nil {
+			return &RowError{Index: i, Err: err}
+		}
+	}
+
+	for _, helmet := range helmets {
+		m.store.helmetsSeq++
+		helmet.ID = m.store.helmetsSeq
+		helmet.CreatedAt = memoryNow()
+		helmet.Version = 1
+		m.store.storeHelmet(helmet)
+		m.store.embedHelmet(helmet)
+	}
+	return nil
+}
+
+func (m MemoryHelmetModel) GetAll(filter HelmetFilter, filters Filters, fieldset Fieldset) ([]*Helmet, Metadata, error) {
+	column, direction := filters.sortColumn(), filters.sortDirection()
+
+	matched := m.filter(filter)
+
+	less := helmetLess(column, direction)
+	sortHelmets(matched, column, direction)
+
+	if filters.usesCursor() {
+		c, err := decodeCursor(filters.Cursor)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		pivot := &Helmet{ID: c.ID}
+		if err := setHelmetSortValue(pivot, column, c.Value); err != nil {
+			return nil, Metadata{}, err
+		}
+
+		// Like the SQL query, collect rows nearest to the cursor first and
+		// fetch one extra row to tell whether there is another page.
+		page := []*Helmet{}
+		if c.Before {
+			for i := len(matched) - 1; i >= 0 && len(page) <= filters.limit(); i-- {
+				if less(matched[i], pivot) {
+					page = append(page, matched[i])
+				}
+			}
+		} else {
+			for i := 0; i < len(matched) && len(page) <= filters.limit(); i++ {
+				if less(pivot, matched[i]) {
+					page = append(page, matched[i])
+				}
+			}
+		}
+
+		helmets, metadata := paginateHelmets(page, filters)
+		m.project(fieldset, helmets...)
+		return helmets, metadata, nil
+	}
+
+	totalRecords := len(matched)
+	helmets := pageOf(matched, filters)
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	if len(helmets) > 0 {
+		metadata.addPageCursors(filters, helmetCursor(helmets[0], filters, true), helmetCursor(helmets[len(helmets)-1], filters, false), len(helmets))
+	}
+
+	m.project(fieldset, helmets...)
+	return helmets, metadata, nil
+}
+
+func (m MemoryHelmetModel) Stream(filter HelmetFilter, fn func(helmet *Helmet) error) error {
+	matched := m.filter(filter)
+	sortHelmets(matched, "id", "ASC")
+
+	for _, helmet := range matched {
+		if err := fn(helmet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m MemoryHelmetModel) Get(id int64) (*Helmet, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	helmet, ok := m.store.helmets[id]
+	if !ok || helmet.DeletedAt != nil {
+		return nil, ErrRecordNotFound
+	}
+	m.store.embedHelmet(&helmet)
+	return &helmet, nil
+}
+
+func (m MemoryHelmetModel) GetWithFieldset(id int64, fieldset Fieldset) (*Helmet, error) {
+	helmet, err := m.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	m.project(fieldset, helmet)
+	return helmet, nil
+}
+
+// project is the in-memory counterpart of reading helmets with a fieldset.
+// The helmets already have the default relations embedded, so only their
+// variants need loading before they are trimmed.
+func (m MemoryHelmetModel) project(fieldset Fieldset, helmets ...*Helmet) {
+	if fieldset.includes("variants") {
+		m.store.mu.RLock()
+		for _, helmet := range helmets {
+			m.store.attachVariants(helmet)
+		}
+		m.store.mu.RUnlock()
+	}
+	fieldset.trimHelmets(helmets...)
+}
+
+func (m MemoryHelmetModel) Update(helmet *Helmet, userID int64, action string) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if err := m.store.checkHelmetUpdate(helmet); err != nil {
+		return err
+	}
+	m.store.updateHelmet(helmet, userID, action)
+	return nil
+}
+
+func (m MemoryHelmetModel) ApplyChangeRequest(helmet *Helmet, request *HelmetChangeRequest) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	current, ok := m.store.changeRequests[request.ID]
+	if !ok || current.Version != request.Version || current.Status != ChangeRequestPending {
+		return ErrEditConflict
+	}
+	if err := m.store.checkHelmetUpdate(helmet); err != nil {
+		return err
+	}
+
+	request.Status = ChangeRequestApproved
+	m.store.reviewChangeRequest(request)
+	m.store.updateHelmet(helmet, request.ReviewerID, RevisionActionChangeRequest)
+	return nil
+}
+
+// checkHelmetUpdate reports the error that saving helmet over the stored one
+// would fail with. The caller must hold the store's lock.
+func (s *memoryStore) checkHelmetUpdate(helmet *Helmet) error {
+	current, ok := s.helmets[helmet.ID]
+	if !ok || current.Version != helmet.Version || current.DeletedAt != nil {
+		return ErrEditConflict
+	}
+	return s.checkHelmet(helmet)
+}
+
+// updateHelmet saves a helmet that has passed checkHelmetUpdate and records
+// the change in its revision history. The caller must hold the store's lock.
+func (s *memoryStore) updateHelmet(helmet *Helmet, userID int64, action string) {
+	current := s.helmets[helmet.ID]
+	helmet.Version++
+	helmet.CreatedAt = current.CreatedAt
+	helmet.Status, helmet.PublishAt, helmet.UnpublishAt = current.Status, current.PublishAt, current.UnpublishAt
+	s.storeHelmet(helmet)
+	s.embedHelmet(helmet)
+	s.insertRevision(&current, helmet, action, userID)
+}
+
+func (m MemoryHelmetModel) UpdateStatus(helmet *Helmet, userID int64) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	current, ok := m.store.helmets[helmet.ID]
+	if !ok || current.Version != helmet.Version || current.DeletedAt != nil {
+		return ErrEditConflict
+	}
+	if !validator.In(helmet.Status, HelmetStatuses...) {
+		return checkViolation("mhelmets", "mhelmets_status_check")
+	}
+
+	current.Status, current.PublishAt, current.UnpublishAt = helmet.Status, helmet.PublishAt, helmet.UnpublishAt
+	current.Version++
+	m.store.helmets[helmet.ID] = current
+	m.store.insertRevision(&current, &current, RevisionActionStatus, userID)
+	helmet.Version = current.Version
+	return nil
+}
+
+func (m MemoryHelmetModel) ProcessSchedules(now time.Time) (published, archived int64, err error) {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	// Go through the helmets in id order, so that their revisions are
+	// recorded in a stable order.
+	ids := make([]int64, 0, len(m.store.helmets))
+	for id := range m.store.helmets {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		helmet := m.store.helmets[id]
+		if helmet.DeletedAt != nil || helmet.PublishAt == nil || helmet.PublishAt.After(now) {
+			continue
+		}
+		if helmet.Status == StatusDraft || helmet.Status == StatusInReview {
+			helmet.Status, helmet.PublishAt = StatusPublished, nil
+			helmet.Version++
+			m.store.helmets[id] = helmet
+			m.store.insertRevision(&helmet, &helmet, RevisionActionScheduledPublish, 0)
+			published++
+		}
+	}
+	for _, id := range ids {
+		helmet := m.store.helmets[id]
+		if helmet.DeletedAt != nil || helmet.UnpublishAt == nil || helmet.UnpublishAt.After(now) {
+			continue
+		}
+		if helmet.Status == StatusPublished {
+			helmet.Status, helmet.UnpublishAt = StatusArchived, nil
+			helmet.Version++
+			m.store.helmets[id] = helmet
+			m.store.insertRevision(&helmet, &helmet, RevisionActionScheduledUnpublish, 0)
+			archived++
+		}
+	}
+	return published, archived, nil
+}
+
+func (m MemoryHelmetModel) Delete(id int64, version int32) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	current, ok := m.store.helmets[id]
+	if !ok || current.DeletedAt != nil || (version != 0 && current.Version != version) {
+		if version != 0 {
+			return ErrEditConflict
+		}
+		return ErrRecordNotFound
+	}
+
+	deletedAt := memoryNow()
+	current.DeletedAt = &deletedAt
+	current.Version++
+	m.store.helmets[id] = current
+	return nil
+}
+
+func (m MemoryHelmetModel) GetAllDeleted(filters Filters) ([]*Helmet, Metadata, error) {
+	column, direction := filters.sortColumn(), filters.sortDirection()
+
+	m.store.mu.RLock()
+	matched := []*Helmet{}
+	for _, helmet := range m.store.helmets {
+		if helmet.DeletedAt == nil {
+			continue
+		}
+		helmet := helmet
+		m.store.embedHelmet(&helmet)
+		matched = append(matched, &helmet)
+	}
+	m.store.mu.RUnlock()
+
+	sortHelmets(matched, column, direction)
+
+	totalRecords := len(matched)
+	helmets := pageOf(matched, filters)
+
+	return helmets, calculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+func (m MemoryHelmetModel) Restore(id int64) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	current, ok := m.store.helmets[id]
+	if !ok || current.DeletedAt == nil {
+		return ErrRecordNotFound
+	}
+
+	current.DeletedAt = nil
+	current.Version++
+	m.store.helmets[id] = current
+	return nil
+}
+
+func (m MemoryHelmetModel) Purge(id int64) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	current, ok := m.store.helmets[id]
+	if !ok || current.DeletedAt == nil {
+		return ErrRecordNotFound
+	}
+
+	delete(m.store.helmets, id)
+	for revisionID, revision := range m.store.revisions {
+		if revision.HelmetID == id {
+			delete(m.store.revisions, revisionID)
+		}
+	}
+	for requestID, request := range m.store.changeRequests {
+		if request.HelmetID == id {
+			delete(m.store.changeRequests, requestID)
+		}
+	}
+	for variantID, variant := range m.store.variants {
+		if variant.HelmetID == id {
+			delete(m.store.variants, variantID)
+		}
+	}
+	for priceID, price := range m.store.prices {
+		if price.HelmetID == id {
+			delete(m.store.prices, priceID)
+		}
+	}
+	for changeID, change := range m.store.priceChanges {
+		if change.HelmetID == id {
+			delete(m.store.priceChanges, changeID)
+		}
+	}
+	for imageID, image := range m.store.images {
+		if image.HelmetID == id {
+			delete(m.store.images, imageID)
+		}
+	}
+	for reviewID, review := range m.store.reviews {
+		if review.HelmetID == id {
+			delete(m.store.reviews, reviewID)
+		}
+	}
+	for _, items := range m.store.wishlistItems {
+		delete(items, id)
+	}
+	return nil
+}
+
+// helmetLess orders helmets by column in the given direction, with the
+// ascending id tiebreaker used by the SQL queries.
+func helmetLess(column, direction string) func(a, b *Helmet) bool {
+	return func(a, b *Helmet) bool {
+		c := compareHelmetColumn(a, b, column)
+		if direction == "DESC" {
+			c = -c
+		}
+		if c != 0 {
+			return c < 0
+		}
+		return a.ID < b.ID
+	}
+}
+
+func sortHelmets(helmets []*Helmet, column, direction string) {
+	less := helmetLess(column, direction)
+	sort.Slice(helmets, func(i, j int) bool {
+		return less(helmets[i], helmets[j])
+	})
+}
+
+// pageOf returns the slice of helmets selected by LIMIT and OFFSET.
+func pageOf(helmets []*Helmet, filters Filters) []*Helmet {
+	start, end := pageBounds(len(helmets), filters)
+	return helmets[start:end]
+}
+
+// filter returns copies of the helmets matching filter, with their search
+// relevance set.
+func (m MemoryHelmetModel) filter(filter HelmetFilter) []*Helmet {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	matched := []*Helmet{}
+	for _, helmet := range m.store.helmets {
+		helmet := helmet
+		ok, relevance := filter.matches(&helmet)
+		if !ok {
+			continue
+		}
+		if filter.Currency != "" {
+			price, ok := m.store.currentPrice(helmet.ID, filter.Currency)
+			if !ok || (filter.PriceMin != 0 && price < filter.PriceMin) || (filter.PriceMax != 0 && price > filter.PriceMax) {
+				continue
+			}
+			helmet.Price = price
+		}
+		if filter.Size != "" || filter.InStock != nil {
+			found := m.store.hasVariant(helmet.ID, filter.Size, filter.InStock != nil)
+			if found != (filter.InStock == nil || *filter.InStock) {
+				continue
+			}
+		}
+		helmet.Relevance = relevance
+		m.store.embedHelmet(&helmet)
+		matched = append(matched, &helmet)
+	}
+	return matched
+}
+
+func containsFold(s, substr string) bool {
+	return substr == "" || strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+func compareHelmetColumn(a, b *Helmet, column string) int {
+	switch column {
+	case "id":
+		return compareInt64(a.ID, b.ID)
+	case "name":
+		return strings.Compare(a.Name, b.Name)
+	case "year":
+		return compareInt64(int64(a.Year), int64(b.Year))
+	case "material":
+		return strings.Compare(a.Material, b.Material)
+	case "ventilation":
+		return compareBool(a.Ventilation, b.Ventilation)
+	case "protection":
+		return strings.Compare(a.Protection, b.Protection)
+	case "weight":
+		return compareFloat64(a.Weight, b.Weight)
+	case "sun_protection":
+		return compareBool(a.SunProtection, b.SunProtection)
+	case "manufacturer":
+		return strings.Compare(manufacturerName(a), manufacturerName(b))
+	case "relevance":
+		return compareFloat64(a.Relevance, b.Relevance)
+	case "price":
+		return compareInt64(int64(a.Price), int64(b.Price))
+	case "rating":
+		return compareFloat64(a.Rating, b.Rating)
+	case "deleted_at":
+		return compareTime(a.DeletedAt, b.DeletedAt)
+	}
+	panic("unsupported sort column: " + column)
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareFloat64(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareTime orders nil times last, as NULLs are in an ascending SQL sort.
+func compareTime(a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	case a.Before(*b):
+		return -1
+	case a.After(*b):
+		return 1
+	}
+	return 0
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case !a:
+		return -1
+	}
+	return 1
+}
+
+// checkHelmet mirrors the check and foreign key constraints on mhelmets.
+// The caller must hold the store lock.
+func (s *memoryStore) checkHelmet(helmet *Helmet) error {
+	if helmet.Year < 1953 || helmet.Year > int32(time.Now().Year()) {
+		return checkViolation("mhelmets", "mhelmets_year_check")
+	}
+	if helmet.Weight < 0.5 || helmet.Weight > 2.5 {
+		return checkViolation("mhelmets", "mhelmets_weight_check")
+	}
+	for _, certification := range helmet.Certifications {
+		if certification.Rating != 0 && (certification.Rating < 1 || certification.Rating > 5) {
+			return checkViolation("mhelmets", "mhelmets_sharp_rating_check")
+		}
+	}
+	if !validator.In(helmet.Status, HelmetStatuses...) {
+		return checkViolation("mhelmets", "mhelmets_status_check")
+	}
+
+	if helmet.ManufacturerID == 0 {
+		return nil
+	}
+	if _, ok := s.manufacturers[helmet.ManufacturerID]; !ok {
+		return foreignKeyViolation("mhelmets", "mhelmets_manufacturer_id_fkey")
+	}
+	return nil
+}
+
+// storeHelmet saves a copy of helmet that doesn't share its certifications
+// with the caller. The caller must hold the store lock.
+func (s *memoryStore) storeHelmet(helmet *Helmet) {
+	sortCertifications(helmet.Certifications)
+	stored := *helmet
+	stored.Certifications = append([]Certification{}, helmet.Certifications...)
+	s.helmets[helmet.ID] = stored
+}
+
+// embedHelmet is the in-memory counterpart of embedHelmets. The caller must
+// hold the store lock.
+func (s *memoryStore) embedHelmet(helmet *Helmet) {
+	s.attachManufacturer(helmet)
+	s.attachPrices(helmet)
+	s.attachImages(helmet)
+	s.attachRatings(helmet)
+}
+
+// attachManufacturer embeds the helmet's manufacturer. The caller must hold
+// the store lock.
+func (s *memoryStore) attachManufacturer(helmet *Helmet) {
+	helmet.Manufacturer = nil
+	if manufacturer, ok := s.manufacturers[helmet.ManufacturerID]; ok {
+		helmet.Manufacturer = &manufacturer
+	}
+}
@@ -0,0 +1,249 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/lib/pq"
+	"time"
+)
+
+// Thumbnail is a scaled down copy of an image.
+type Thumbnail struct {
+	URL    string `json:"url"`
+	Width  int32  `json:"width"`
+	Height int32  `json:"height"`
+}
+
+// Image is a photo of a helmet. Images are shown in ascending Position
+// order. Keys holds the storage keys of the original and all of its
+// thumbnails, so that the files can be removed along with the image.
+type Image struct {
+	ID          int64                `json:"id"`
+	HelmetID    int64                `json:"helmet_id"`
+	CreatedAt   time.Time            `json:"-"`
+	Position    int32                `json:"position"`
+	ContentType string               `json:"content_type"`
+	Size        int64                `json:"size"`
+	Width       int32                `json:"width"`
+	Height      int32                `json:"height"`
+	URL         string               `json:"url"`
+	Thumbnails  map[string]Thumbnail `json:"thumbnails"`
+	Keys        []string             `json:"-"`
+}
+
+type ImageModel struct {
+	DB *sql.DB
+}
+
+// Insert adds the image after the helmet's existing images.
+func (m ImageModel) Insert(image *Image) error {
+	thumbnails, err := json.Marshal(image.Thumbnails)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO mhelmet_images (helmet_id, position, content_type, size, width, height, url, thumbnails, keys)
+		VALUES ($1, (SELECT COALESCE(MAX(position), 0) + 1 FROM mhelmet_images WHERE helmet_id = $1), $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, position`
+
+	args := []interface{}{
+		image.HelmetID,
+		image.ContentType,
+		image.Size,
+		image.Width,
+		image.Height,
+		image.URL,
+		thumbnails,
+		pq.Array(image.Keys),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query, args...).Scan(&image.ID, &image.CreatedAt, &image.Position)
+	return translateError(err)
+}
+
+func (m ImageModel) GetAllForHelmet(helmetID int64) ([]*Image, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return queryImages(ctx, m.DB, []int64{helmetID})
+}
+
+func (m ImageModel) Get(helmetID, id int64) (*Image, error) {
+	if helmetID < 1 || id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, helmet_id, created_at, position, content_type, size, width, height, url, thumbnails, keys
+		FROM mhelmet_images
+		WHERE helmet_id = $1 AND id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	image, err := scanImage(m.DB.QueryRowContext(ctx, query, helmetID, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return image, nil
+}
+
+// Delete removes an image and moves the images after it up one position, so
+// that positions stay contiguous.
+func (m ImageModel) Delete(helmetID, id int64) error {
+	if helmetID < 1 || id < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		DELETE FROM mhelmet_images
+		WHERE helmet_id = $1 AND id = $2
+		RETURNING position`
+
+	var position int32
+	err = tx.QueryRowContext(ctx, query, helmetID, id).Scan(&position)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	query = `
+		UPDATE mhelmet_images
+		SET position = position - 1
+		WHERE helmet_id = $1 AND position > $2`
+
+	_, err = tx.ExecContext(ctx, query, helmetID, position)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Reorder sets the positions of a helmet's images to their order in ids,
+// which must list every image of the helmet exactly once.
+func (m ImageModel) Reorder(helmetID int64, ids []int64) error {
+	query := `
+		UPDATE mhelmet_images
+		SET position = array_position($2::bigint[], id)
+		WHERE helmet_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, helmetID, pq.Array(ids))
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanImage(row rowScanner) (*Image, error) {
+	var image Image
+	var thumbnails []byte
+
+	err := row.Scan(
+		&image.ID,
+		&image.HelmetID,
+		&image.CreatedAt,
+		&image.Position,
+		&image.ContentType,
+		&image.Size,
+		&image.Width,
+		&image.Height,
+		&image.URL,
+		&thumbnails,
+		pq.Array(&image.Keys),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(thumbnails, &image.Thumbnails); err != nil {
+		return nil, err
+	}
+	return &image, nil
+}
+
+func queryImages(ctx context.Context, db *sql.DB, helmetIDs []int64) ([]*Image, error) {
+	query := `
+		SELECT id, helmet_id, created_at, position, content_type, size, width, height, url, thumbnails, keys
+		FROM mhelmet_images
+		WHERE helmet_id = ANY($1)
+		ORDER BY helmet_id, position, id`
+
+	rows, err := db.QueryContext(ctx, query, pq.Array(helmetIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	images := []*Image{}
+
+	for rows.Next() {
+		image, err := scanImage(rows)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, image)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return images, nil
+}
+
+// attachImages loads the images of helmets and embeds them.
+func attachImages(ctx context.Context, db *sql.DB, helmets ...*Helmet) error {
+	if len(helmets) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(helmets))
+	for i, helmet := range helmets {
+		ids[i] = helmet.ID
+	}
+
+	images, err := queryImages(ctx, db, ids)
+	if err != nil {
+		return err
+	}
+
+	byHelmet := make(map[int64][]*Image)
+	for _, image := range images {
+		byHelmet[image.HelmetID] = append(byHelmet[image.HelmetID], image)
+	}
+
+	for _, helmet := range helmets {
+		helmet.Images = byHelmet[helmet.ID]
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package data
+
+import "sort"
+
+type MemoryImageModel struct {
+	store *memoryStore
+}
+
+func (m MemoryImageModel) Insert(image *Image) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if _, ok := m.store.helmets[image.HelmetID]; !ok {
+		return foreignKeyViolation("mhelmet_images", "mhelmet_images_helmet_id_fkey")
+	}
+
+	image.Position = 1
+	for _, other := range m.store.images {
+		if other.HelmetID == image.HelmetID && other.Position >= image.Position {
+			image.Position = other.Position + 1
+		}
+	}
+
+	m.store.imagesSeq++
+	image.ID = m.store.imagesSeq
+	image.CreatedAt = memoryNow()
+	m.store.images[image.ID] = *image
+	return nil
+}
+
+func (m MemoryImageModel) GetAllForHelmet(helmetID int64) ([]*Image, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	return m.store.imagesForHelmet(helmetID), nil
+}
+
+func (m MemoryImageModel) Get(helmetID, id int64) (*Image, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	image, ok := m.store.images[id]
+	if !ok || image.HelmetID != helmetID {
+		return nil, ErrRecordNotFound
+	}
+	return &image, nil
+}
+
+func (m MemoryImageModel) Delete(helmetID, id int64) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	image, ok := m.store.images[id]
+	if !ok || image.HelmetID != helmetID {
+		return ErrRecordNotFound
+	}
+	delete(m.store.images, id)
+
+	for otherID, other := range m.store.images {
+		if other.HelmetID == helmetID && other.Position > image.Position {
+			other.Position--
+			m.store.images[otherID] = other
+		}
+	}
+	return nil
+}
+
+func (m MemoryImageModel) Reorder(helmetID int64, ids []int64) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	for i, id := range ids {
+		image, ok := m.store.images[id]
+		if !ok || image.HelmetID != helmetID {
+			continue
+		}
+		image.Position = int32(i + 1)
+		m.store.images[id] = image
+	}
+	return nil
+}
+
+// imagesForHelmet returns the images of a helmet in display order. The
+// caller must hold the store lock.
+func (s *memoryStore) imagesForHelmet(helmetID int64) []*Image {
+	images := []*Image{}
+	for _, image := range s.images {
+		if image.HelmetID != helmetID {
+			continue
+		}
+		image := image
+		images = append(images, &image)
+	}
+
+	sort.Slice(images, func(i, j int) bool {
+		if images[i].Position != images[j].Position {
+			return images[i].Position < images[j].Position
+		}
+		return images[i].ID < images[j].ID
+	})
+	return images
+}
+
+// attachImages embeds the helmet's images. The caller must hold the store
+// lock.
+func (s *memoryStore) attachImages(helmet *Helmet) {
+	helmet.Images = nil
+	if images := s.imagesForHelmet(helmet.ID); len(images) > 0 {
+		helmet.Images = images
+	}
+}
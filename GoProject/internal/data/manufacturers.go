@@ -0,0 +1,258 @@
+package data
+
+import (
+	"GoProject/internal/validator"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"strings"
+	"time"
+)
+
+var (
+	ErrDuplicateManufacturer = errors.New("duplicate manufacturer name")
+	ErrManufacturerInUse     = errors.New("manufacturer has helmets")
+)
+
+type Manufacturer struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"-"`
+	Name      string    `json:"name"`
+	Country   string    `json:"country,omitempty"`
+	Website   string    `json:"website,omitempty"`
+	Version   int32     `json:"version"`
+}
+
+func ValidateManufacturer(v *validator.Validator, manufacturer *Manufacturer) {
+	v.Check(strings.TrimSpace(manufacturer.Name) != "", "name", "must be provided")
+	v.Check(len(manufacturer.Name) <= 200, "name", "must not be more than 200 bytes long")
+	v.Check(len(manufacturer.Country) <= 100, "country", "must not be more than 100 bytes long")
+	v.Check(len(manufacturer.Website) <= 500, "website", "must not be more than 500 bytes long")
+	if manufacturer.Website != "" {
+		v.Check(strings.HasPrefix(manufacturer.Website, "http://") || strings.HasPrefix(manufacturer.Website, "https://"), "website", "must be an http or https URL")
+	}
+}
+
+type ManufacturerModel struct {
+	DB *sql.DB
+}
+
+func (m ManufacturerModel) Insert(manufacturer *Manufacturer) error {
+	query := `
+		INSERT INTO manufacturers (name, country, website)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, version`
+
+	args := []interface{}{manufacturer.Name, manufacturer.Country, manufacturer.Website}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&manufacturer.ID, &manufacturer.CreatedAt, &manufacturer.Version)
+	if err != nil {
+		return translateError(err)
+	}
+	return nil
+}
+
+func (m ManufacturerModel) GetAll(name string, filters Filters) ([]*Manufacturer, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, created_at, name, country, website, version
+		FROM manufacturers
+		WHERE (STRPOS(LOWER(name), LOWER($1)) > 0 OR $1 = '')
+		ORDER BY %s %s, id ASC
+		LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, name, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	manufacturers := []*Manufacturer{}
+
+	for rows.Next() {
+		var manufacturer Manufacturer
+		err := rows.Scan(
+			&totalRecords,
+			&manufacturer.ID,
+			&manufacturer.CreatedAt,
+			&manufacturer.Name,
+			&manufacturer.Country,
+			&manufacturer.Website,
+			&manufacturer.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		manufacturers = append(manufacturers, &manufacturer)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return manufacturers, metadata, nil
+}
+
+func (m ManufacturerModel) Get(id int64) (*Manufacturer, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, name, country, website, version
+		FROM manufacturers
+		WHERE id = $1`
+
+	var manufacturer Manufacturer
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&manufacturer.ID,
+		&manufacturer.CreatedAt,
+		&manufacturer.Name,
+		&manufacturer.Country,
+		&manufacturer.Website,
+		&manufacturer.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &manufacturer, nil
+}
+
+func (m ManufacturerModel) Update(manufacturer *Manufacturer) error {
+	query := `
+		UPDATE manufacturers
+		SET name = $1, country = $2, website = $3, version = version + 1
+		WHERE id = $4 AND version = $5
+		RETURNING version`
+
+	args := []interface{}{
+		manufacturer.Name,
+		manufacturer.Country,
+		manufacturer.Website,
+		manufacturer.ID,
+		manufacturer.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&manufacturer.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return translateError(err)
+		}
+	}
+	return nil
+}
+
+// Delete removes a manufacturer. Manufacturers that still have helmets,
+// including helmets in the trash, can't be deleted.
+func (m ManufacturerModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM manufacturers
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		var constraintErr *ConstraintError
+		switch {
+		case errors.As(translateError(err), &constraintErr) && constraintErr.Constraint == "mhelmets_manufacturer_id_fkey":
+			return ErrManufacturerInUse
+		default:
+			return err
+		}
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// attachManufacturers loads the manufacturers of helmets in a single query
+// and embeds them.
+func attachManufacturers(ctx context.Context, db *sql.DB, helmets ...*Helmet) error {
+	var ids []int64
+	for _, helmet := range helmets {
+		if helmet.ManufacturerID != 0 {
+			ids = append(ids, helmet.ManufacturerID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `
+		SELECT id, created_at, name, country, website, version
+		FROM manufacturers
+		WHERE id = ANY($1)`
+
+	rows, err := db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	manufacturers := make(map[int64]*Manufacturer)
+	for rows.Next() {
+		var manufacturer Manufacturer
+		err := rows.Scan(
+			&manufacturer.ID,
+			&manufacturer.CreatedAt,
+			&manufacturer.Name,
+			&manufacturer.Country,
+			&manufacturer.Website,
+			&manufacturer.Version,
+		)
+		if err != nil {
+			return err
+		}
+		manufacturers[manufacturer.ID] = &manufacturer
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, helmet := range helmets {
+		helmet.Manufacturer = manufacturers[helmet.ManufacturerID]
+	}
+	return nil
+}
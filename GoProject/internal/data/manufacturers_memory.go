@@ -0,0 +1,121 @@
+package data
+
+import (
+	"sort"
+	"strings"
+)
+
+type MemoryManufacturerModel struct {
+	store *memoryStore
+}
+
+func (m MemoryManufacturerModel) Insert(manufacturer *Manufacturer) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if m.store.manufacturerNameTaken(manufacturer) {
+		return uniqueViolation("manufacturers_name_idx")
+	}
+
+	m.store.manufacturersSeq++
+	manufacturer.ID = m.store.manufacturersSeq
+	manufacturer.CreatedAt = memoryNow()
+	manufacturer.Version = 1
+	m.store.manufacturers[manufacturer.ID] = *manufacturer
+	return nil
+}
+
+func (m MemoryManufacturerModel) GetAll(name string, filters Filters) ([]*Manufacturer, Metadata, error) {
+	column, direction := filters.sortColumn(), filters.sortDirection()
+
+	m.store.mu.RLock()
+	matched := []*Manufacturer{}
+	for _, manufacturer := range m.store.manufacturers {
+		if !containsFold(manufacturer.Name, name) {
+			continue
+		}
+		manufacturer := manufacturer
+		matched = append(matched, &manufacturer)
+	}
+	m.store.mu.RUnlock()
+
+	sort.Slice(matched, func(i, j int) bool {
+		var c int
+		switch column {
+		case "name":
+			c = strings.Compare(matched[i].Name, matched[j].Name)
+		case "country":
+			c = strings.Compare(matched[i].Country, matched[j].Country)
+		}
+		if direction == "DESC" {
+			c = -c
+		}
+		if c != 0 {
+			return c < 0
+		}
+		if column == "id" && direction == "DESC" {
+			return matched[i].ID > matched[j].ID
+		}
+		return matched[i].ID < matched[j].ID
+	})
+
+	start, end := pageBounds(len(matched), filters)
+	return matched[start:end], calculateMetadata(len(matched), filters.Page, filters.PageSize), nil
+}
+
+func (m MemoryManufacturerModel) Get(id int64) (*Manufacturer, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	manufacturer, ok := m.store.manufacturers[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	return &manufacturer, nil
+}
+
+func (m MemoryManufacturerModel) Update(manufacturer *Manufacturer) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	current, ok := m.store.manufacturers[manufacturer.ID]
+	if !ok || current.Version != manufacturer.Version {
+		return ErrEditConflict
+	}
+	if m.store.manufacturerNameTaken(manufacturer) {
+		return uniqueViolation("manufacturers_name_idx")
+	}
+
+	manufacturer.Version++
+	manufacturer.CreatedAt = current.CreatedAt
+	m.store.manufacturers[manufacturer.ID] = *manufacturer
+	return nil
+}
+
+func (m MemoryManufacturerModel) Delete(id int64) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if _, ok := m.store.manufacturers[id]; !ok {
+		return ErrRecordNotFound
+	}
+	for _, helmet := range m.store.helmets {
+		if helmet.ManufacturerID == id {
+			return ErrManufacturerInUse
+		}
+	}
+
+	delete(m.store.manufacturers, id)
+	return nil
+}
+
+// manufacturerNameTaken mirrors the case-insensitive manufacturers_name_idx
+// index. The caller must hold the store lock.
+func (s *memoryStore) manufacturerNameTaken(manufacturer *Manufacturer) bool {
+	for _, other := range s.manufacturers {
+		if other.ID != manufacturer.ID && strings.EqualFold(other.Name, manufacturer.Name) {
+			return true
+		}
+	}
+	return false
+}
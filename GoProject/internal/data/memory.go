@@ -0,0 +1,100 @@
+package data
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// memoryStore keeps every table of the in-memory backend behind a single
+// lock, so that operations spanning several tables (token lookups, permission
+// joins) see a consistent snapshot just like a SQL join would.
+type memoryStore struct {
+	mu sync.RWMutex
+
+	helmets           map[int64]Helmet
+	helmetsSeq        int64
+	revisions         map[int64]HelmetRevision
+	revisionsSeq      int64
+	changeRequests    map[int64]HelmetChangeRequest
+	changeRequestsSeq int64
+	manufacturers     map[int64]Manufacturer
+	manufacturersSeq  int64
+	variants          map[int64]Variant
+	variantsSeq       int64
+	prices            map[int64]Price
+	pricesSeq         int64
+	priceChanges      map[int64]PriceChange
+	priceChangesSeq   int64
+	images            map[int64]Image
+	imagesSeq         int64
+	reviews           map[int64]Review
+	reviewsSeq        int64
+	wishlists         map[int64]Wishlist
+	wishlistsSeq      int64
+	wishlistItems     map[int64]map[int64]time.Time
+	users             map[int64]User
+	usersSeq          int64
+	tokens            map[string]Token
+	permissions       map[int64]string
+	usersPermissions  map[int64]map[int64]bool
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		helmets:        make(map[int64]Helmet),
+		revisions:      make(map[int64]HelmetRevision),
+		changeRequests: make(map[int64]HelmetChangeRequest),
+		manufacturers:  make(map[int64]Manufacturer),
+		variants:       make(map[int64]Variant),
+		prices:         make(map[int64]Price),
+		priceChanges:   make(map[int64]PriceChange),
+		images:         make(map[int64]Image),
+		reviews:        make(map[int64]Review),
+		wishlists:      make(map[int64]Wishlist),
+		wishlistItems:  make(map[int64]map[int64]time.Time),
+		users:          make(map[int64]User),
+		tokens:         make(map[string]Token),
+		permissions: map[int64]string{
+			1: "mhelmets:read",
+			2: "mhelmets:write",
+			3: "mhelmets:purge",
+			4: "manufacturers:read",
+			5: "manufacturers:write",
+			6: "mhelmets:publish",
+		},
+		usersPermissions: make(map[int64]map[int64]bool),
+	}
+}
+
+func memoryNow() time.Time {
+	return time.Now().Truncate(time.Second)
+}
+
+// uniqueViolation, checkViolation and foreignKeyViolation return the errors
+// the database fails with when the named constraint is violated.
+func uniqueViolation(name string) error {
+	return constraintError(name, fmt.Errorf("duplicate key value violates unique constraint %q", name))
+}
+
+func checkViolation(table, name string) error {
+	return constraintError(name, fmt.Errorf("new row for relation %q violates check constraint %q", table, name))
+}
+
+func foreignKeyViolation(table, name string) error {
+	return constraintError(name, fmt.Errorf("insert or update on table %q violates foreign key constraint %q", table, name))
+}
+
+// pageBounds returns the slice bounds of the page selected by filters out of
+// length sorted records.
+func pageBounds(length int, filters Filters) (int, int) {
+	start := filters.offset()
+	if start > length {
+		start = length
+	}
+	end := start + filters.limit()
+	if end > length {
+		end = length
+	}
+	return start, end
+}
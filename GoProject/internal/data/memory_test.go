@@ -0,0 +1,344 @@
+package data
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// The memory models have to fail with the same errors as the database ones,
+// so that handlers treat both backends alike.
+func TestMemoryErrors(t *testing.T) {
+	models := NewMemoryModels()
+
+	user := &User{Name: "Test User", Email: "test@example.com", Activated: true}
+	if err := models.Users.Insert(user); err != nil {
+		t.Fatal(err)
+	}
+	stale := *user
+	if err := models.Users.Update(user); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		fn      func() error
+		wantErr error
+	}{
+		{
+			name:    "Duplicate email",
+			fn:      func() error { return models.Users.Insert(&User{Name: "Other", Email: "TEST@example.com"}) },
+			wantErr: ErrDuplicateEmail,
+		},
+		{
+			name:    "Stale user",
+			fn:      func() error { return models.Users.Update(&stale) },
+			wantErr: ErrEditConflict,
+		},
+		{
+			name: "Missing user",
+			fn: func() error {
+				_, err := models.Users.GetByEmail("missing@example.com")
+				return err
+			},
+			wantErr: ErrRecordNotFound,
+		},
+		{
+			name: "Missing helmet",
+			fn: func() error {
+				_, err := models.Helmets.Get(99)
+				return err
+			},
+			wantErr: ErrRecordNotFound,
+		},
+		{
+			name:    "Deleting a missing helmet",
+			fn:      func() error { return models.Helmets.Delete(99, 0) },
+			wantErr: ErrRecordNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.fn()
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Constraint violations have to be reported the same way translateError
+// reports them for the database models.
+func TestMemoryConstraintErrors(t *testing.T) {
+	models := NewMemoryModels()
+
+	user := &User{Name: "Test User", Email: "test@example.com", Activated: true}
+	if err := models.Users.Insert(user); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.Permissions.AddForUser(user.ID, "mhelmets:read"); err != nil {
+		t.Fatal(err)
+	}
+	token, err := models.Tokens.New(user.ID, time.Hour, ScopeAuthentication)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wishlist := &Wishlist{UserID: user.ID, Name: "Favourites"}
+	if err := models.Wishlists.Insert(wishlist); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		fn             func() error
+		wantConstraint string
+		wantSentinel   error
+	}{
+		{
+			name:           "Duplicate email",
+			fn:             func() error { return models.Users.Insert(&User{Name: "Other", Email: "TEST@example.com"}) },
+			wantConstraint: "users_email_key",
+			wantSentinel:   ErrDuplicateEmail,
+		},
+		{
+			name:           "Permission for missing user",
+			fn:             func() error { return models.Permissions.AddForUser(99, "mhelmets:read") },
+			wantConstraint: "users_permissions_user_id_fkey",
+		},
+		{
+			name:           "Duplicate permission",
+			fn:             func() error { return models.Permissions.AddForUser(user.ID, "mhelmets:read") },
+			wantConstraint: "users_permissions_pkey",
+		},
+		{
+			name: "Token for missing user",
+			fn: func() error {
+				_, err := models.Tokens.New(99, time.Hour, ScopeAuthentication)
+				return err
+			},
+			wantConstraint: "tokens_user_id_fkey",
+		},
+		{
+			name:           "Duplicate token",
+			fn:             func() error { return models.Tokens.Insert(token) },
+			wantConstraint: "tokens_pkey",
+		},
+		{
+			name: "Missing manufacturer",
+			fn: func() error {
+				return models.Helmets.Insert(&Helmet{Name: "Helmet", Year: 2020, Material: "carbon", Protection: "full face", Weight: 1.4, ManufacturerID: 99, Status: StatusDraft})
+			},
+			wantConstraint: "mhelmets_manufacturer_id_fkey",
+		},
+		{
+			name:           "Variant of missing helmet",
+			fn:             func() error { return models.Variants.Insert(&Variant{HelmetID: 99, Size: "M", SKU: "X-M"}) },
+			wantConstraint: "mhelmet_variants_helmet_id_fkey",
+		},
+		{
+			name:           "Wishlist item for missing helmet",
+			fn:             func() error { return models.Wishlists.AddHelmet(wishlist.ID, 99) },
+			wantConstraint: "wishlist_items_helmet_id_fkey",
+		},
+		{
+			name:           "Change request for missing helmet",
+			fn:             func() error { return models.ChangeRequests.Insert(&HelmetChangeRequest{HelmetID: 99, UserID: user.ID}) },
+			wantConstraint: "mhelmet_change_requests_helmet_id_fkey",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.fn()
+
+			var constraintErr *ConstraintError
+			if !errors.As(err, &constraintErr) {
+				t.Fatalf("got error %v, want a *ConstraintError", err)
+			}
+			if constraintErr.Constraint != tt.wantConstraint {
+				t.Errorf("got constraint %q, want %q", constraintErr.Constraint, tt.wantConstraint)
+			}
+			if tt.wantSentinel != nil && !errors.Is(err, tt.wantSentinel) {
+				t.Errorf("got error %v, want it to match %v", err, tt.wantSentinel)
+			}
+		})
+	}
+}
+
+func TestMemoryInsertManyRowError(t *testing.T) {
+	models := NewMemoryModels()
+
+	helmets := []*Helmet{
+		{Name: "RPHA 11", Year: 2020, Material: "carbon", Protection: "full face", Weight: 1.4, Status: StatusDraft},
+		{Name: "Neotec", Year: 2019, Material: "fibreglass", Protection: "modular", Weight: 1.7, ManufacturerID: 99, Status: StatusDraft},
+	}
+
+	err := models.Helmets.InsertMany(helmets)
+
+	var rowErr *RowError
+	if !errors.As(err, &rowErr) {
+		t.Fatalf("got error %v, want a *RowError", err)
+	}
+	if rowErr.Index != 1 {
+		t.Errorf("got index %d, want 1", rowErr.Index)
+	}
+
+	var constraintErr *ConstraintError
+	if !errors.As(err, &constraintErr) || constraintErr.Field != "manufacturer_id" {
+		t.Errorf("got error %v, want a manufacturer_id constraint error", err)
+	}
+
+	if _, err := models.Helmets.Get(1); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("got error %v for the first helmet, want ErrRecordNotFound", err)
+	}
+}
+
+func TestMemoryInsertManyAttachesManufacturer(t *testing.T) {
+	models := NewMemoryModels()
+
+	manufacturer := &Manufacturer{Name: "HJC"}
+	if err := models.Manufacturers.Insert(manufacturer); err != nil {
+		t.Fatal(err)
+	}
+
+	helmets := []*Helmet{
+		{Name: "RPHA 11", Year: 2020, Material: "carbon", Protection: "full face", Weight: 1.4, ManufacturerID: manufacturer.ID, Status: StatusDraft},
+	}
+	if err := models.Helmets.InsertMany(helmets); err != nil {
+		t.Fatal(err)
+	}
+
+	if helmets[0].Manufacturer == nil || helmets[0].Manufacturer.Name != "HJC" {
+		t.Errorf("got manufacturer %+v, want HJC", helmets[0].Manufacturer)
+	}
+}
+
+// An approval that conflicts on either the request or the helmet must leave
+// both untouched.
+func TestMemoryApplyChangeRequestConflicts(t *testing.T) {
+	tests := []struct {
+		name          string
+		staleRequest  bool
+		staleHelmet   bool
+		wantConflict  bool
+		wantName      string
+		wantStatus    string
+		wantRevisions int
+	}{
+		{"Current", false, false, false, "RPHA 11 Carbon", ChangeRequestApproved, 1},
+		{"Stale request", true, false, true, "RPHA 11", ChangeRequestPending, 0},
+		{"Stale helmet", false, true, true, "RPHA 11", ChangeRequestPending, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			models := NewMemoryModels()
+
+			user := &User{Name: "Test User", Email: "test@example.com", Activated: true}
+			if err := models.Users.Insert(user); err != nil {
+				t.Fatal(err)
+			}
+			helmet := &Helmet{Name: "RPHA 11", Year: 2020, Material: "carbon", Protection: "full face", Weight: 1.4, Status: StatusDraft}
+			if err := models.Helmets.Insert(helmet); err != nil {
+				t.Fatal(err)
+			}
+			request := &HelmetChangeRequest{HelmetID: helmet.ID, UserID: user.ID, BaseVersion: helmet.Version}
+			if err := models.ChangeRequests.Insert(request); err != nil {
+				t.Fatal(err)
+			}
+
+			changed := *helmet
+			changed.Name = "RPHA 11 Carbon"
+			review := *request
+			review.ReviewerID = user.ID
+			if tt.staleRequest {
+				review.Version--
+			}
+			if tt.staleHelmet {
+				changed.Version--
+			}
+
+			err := models.Helmets.ApplyChangeRequest(&changed, &review)
+			if errors.Is(err, ErrEditConflict) != tt.wantConflict {
+				t.Fatalf("got error %v, want conflict %t", err, tt.wantConflict)
+			}
+
+			gotHelmet, err := models.Helmets.Get(helmet.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotHelmet.Name != tt.wantName {
+				t.Errorf("got helmet name %q, want %q", gotHelmet.Name, tt.wantName)
+			}
+
+			gotRequest, err := models.ChangeRequests.Get(helmet.ID, request.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotRequest.Status != tt.wantStatus {
+				t.Errorf("got request status %q, want %q", gotRequest.Status, tt.wantStatus)
+			}
+
+			revisions, _, err := models.HelmetRevisions.GetAllForHelmet(helmet.ID, Filters{Page: 1, PageSize: 20, Sort: "id", SortSafelist: []string{"id"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(revisions) != tt.wantRevisions {
+				t.Errorf("got %d revisions, want %d", len(revisions), tt.wantRevisions)
+			}
+			if tt.wantRevisions > 0 && revisions[0].Action != RevisionActionChangeRequest {
+				t.Errorf("got revision action %q, want %q", revisions[0].Action, RevisionActionChangeRequest)
+			}
+		})
+	}
+}
+
+// Status changes bump the helmet version like any other change, so they have
+// to be in the revision history too.
+func TestMemoryStatusChangesRecordRevisions(t *testing.T) {
+	models := NewMemoryModels()
+
+	user := &User{Name: "Test User", Email: "test@example.com", Activated: true}
+	if err := models.Users.Insert(user); err != nil {
+		t.Fatal(err)
+	}
+	helmet := &Helmet{Name: "RPHA 11", Year: 2020, Material: "carbon", Protection: "full face", Weight: 1.4, Status: StatusDraft}
+	if err := models.Helmets.Insert(helmet); err != nil {
+		t.Fatal(err)
+	}
+
+	unpublishAt := time.Now().Add(-time.Minute)
+	helmet.Status, helmet.UnpublishAt = StatusPublished, &unpublishAt
+	if err := models.Helmets.UpdateStatus(helmet, user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	published, archived, err := models.Helmets.ProcessSchedules(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if published != 0 || archived != 1 {
+		t.Fatalf("got %d published and %d archived, want 0 and 1", published, archived)
+	}
+
+	revisions, _, err := models.HelmetRevisions.GetAllForHelmet(helmet.ID, Filters{Page: 1, PageSize: 20, Sort: "id", SortSafelist: []string{"id"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []HelmetRevision{
+		{Version: 2, Action: RevisionActionStatus, Status: StatusPublished, UserID: user.ID},
+		{Version: 3, Action: RevisionActionScheduledUnpublish, Status: StatusArchived},
+	}
+	if len(revisions) != len(want) {
+		t.Fatalf("got %d revisions, want %d", len(revisions), len(want))
+	}
+	for i, revision := range revisions {
+		if revision.Version != want[i].Version || revision.Action != want[i].Action || revision.Status != want[i].Status || revision.UserID != want[i].UserID {
+			t.Errorf("got revision %d at version %d by %d, %s to %s, want version %d by %d, %s to %s",
+				i, revision.Version, revision.UserID, revision.Action, revision.Status,
+				want[i].Version, want[i].UserID, want[i].Action, want[i].Status)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package data
 import (
 	"database/sql"
 	"errors"
+	"time"
 )
 
 var (
@@ -10,18 +11,151 @@ var (
 	ErrEditConflict   = errors.New("edit conflict")
 )
 
+type HelmetRepository interface {
+	Insert(helmet *Helmet) error
+	InsertMany(helmets []*Helmet) error
+	GetAll(filter HelmetFilter, filters Filters, fieldset Fieldset) ([]*Helmet, Metadata, error)
+	Stream(filter HelmetFilter, fn func(helmet *Helmet) error) error
+	GetFacets(filter HelmetFilter, facets []string) (Facets, error)
+	Get(id int64) (*Helmet, error)
+	GetWithFieldset(id int64, fieldset Fieldset) (*Helmet, error)
+	Update(helmet *Helmet, userID int64, action string) error
+	ApplyChangeRequest(helmet *Helmet, request *HelmetChangeRequest) error
+	UpdateStatus(helmet *Helmet, userID int64) error
+	ProcessSchedules(now time.Time) (published, archived int64, err error)
+	Delete(id int64, version int32) error
+	GetAllDeleted(filters Filters) ([]*Helmet, Metadata, error)
+	Restore(id int64) error
+	Purge(id int64) error
+}
+
+type HelmetRevisionRepository interface {
+	GetAllForHelmet(helmetID int64, filters Filters) ([]*HelmetRevision, Metadata, error)
+	Get(helmetID, id int64) (*HelmetRevision, error)
+}
+
+type HelmetChangeRequestRepository interface {
+	Insert(request *HelmetChangeRequest) error
+	GetAll(filter ChangeRequestFilter, filters Filters) ([]*HelmetChangeRequest, Metadata, error)
+	Get(helmetID, id int64) (*HelmetChangeRequest, error)
+	Review(request *HelmetChangeRequest) error
+}
+
+type ManufacturerRepository interface {
+	Insert(manufacturer *Manufacturer) error
+	GetAll(name string, filters Filters) ([]*Manufacturer, Metadata, error)
+	Get(id int64) (*Manufacturer, error)
+	Update(manufacturer *Manufacturer) error
+	Delete(id int64) error
+}
+
+type VariantRepository interface {
+	Insert(variant *Variant) error
+	GetAllForHelmet(helmetID int64) ([]*Variant, error)
+	Get(helmetID, id int64) (*Variant, error)
+	Update(variant *Variant) error
+	Delete(helmetID, id int64) error
+}
+
+type ImageRepository interface {
+	Insert(image *Image) error
+	GetAllForHelmet(helmetID int64) ([]*Image, error)
+	Get(helmetID, id int64) (*Image, error)
+	Delete(helmetID, id int64) error
+	Reorder(helmetID int64, ids []int64) error
+}
+
+type ReviewRepository interface {
+	Insert(review *Review) error
+	GetAllForHelmet(helmetID int64, filters Filters) ([]*Review, Metadata, error)
+	Get(helmetID, id int64) (*Review, error)
+	Update(review *Review) error
+	Delete(helmetID, id int64) error
+}
+
+type WishlistRepository interface {
+	Insert(wishlist *Wishlist) error
+	GetAllForUser(userID int64) ([]*Wishlist, error)
+	Get(userID, id int64) (*Wishlist, error)
+	Update(wishlist *Wishlist) error
+	Delete(userID, id int64) error
+	AddHelmet(wishlistID, helmetID int64) error
+	RemoveHelmet(wishlistID, helmetID int64) error
+	GetItems(wishlistID int64, filters Filters) ([]*WishlistItem, Metadata, error)
+	Favourited(userID int64, helmetIDs []int64) (map[int64]bool, error)
+}
+
+type PriceRepository interface {
+	Set(price *Price, userID int64) error
+	Delete(helmetID, id int64, userID int64) error
+	GetTimeline(helmetID int64, currency string) ([]*Price, error)
+	GetHistory(helmetID int64, filters Filters) ([]*PriceChange, Metadata, error)
+}
+
+type PermissionRepository interface {
+	GetAllForUser(userID int64) (Permissions, error)
+	AddForUser(userID int64, codes ...string) error
+}
+
+type TokenRepository interface {
+	New(userID int64, ttl time.Duration, scope string) (*Token, error)
+	Insert(token *Token) error
+	DeleteAllForUser(scope string, userID int64) error
+}
+
+type UserRepository interface {
+	Insert(user *User) error
+	GetByEmail(email string) (*User, error)
+	Update(user *User) error
+	GetForToken(tokenScope, tokenPlaintext string) (*User, error)
+}
+
 type Models struct {
-	Helmets     HelmetModel
-	Permissions PermissionModel
-	Tokens      TokenModel
-	Users       UserModel
+	Helmets         HelmetRepository
+	HelmetRevisions HelmetRevisionRepository
+	ChangeRequests  HelmetChangeRequestRepository
+	Manufacturers   ManufacturerRepository
+	Images          ImageRepository
+	Permissions     PermissionRepository
+	Prices          PriceRepository
+	Reviews         ReviewRepository
+	Tokens          TokenRepository
+	Users           UserRepository
+	Variants        VariantRepository
+	Wishlists       WishlistRepository
 }
 
 func NewModels(db *sql.DB) Models {
 	return Models{
-		Helmets:     HelmetModel{DB: db},
-		Permissions: PermissionModel{DB: db},
-		Tokens:      TokenModel{DB: db},
-		Users:       UserModel{DB: db},
+		Helmets:         HelmetModel{DB: db},
+		HelmetRevisions: HelmetRevisionModel{DB: db},
+		ChangeRequests:  HelmetChangeRequestModel{DB: db},
+		Manufacturers:   ManufacturerModel{DB: db},
+		Images:          ImageModel{DB: db},
+		Permissions:     PermissionModel{DB: db},
+		Prices:          PriceModel{DB: db},
+		Reviews:         ReviewModel{DB: db},
+		Tokens:          TokenModel{DB: db},
+		Users:           UserModel{DB: db},
+		Variants:        VariantModel{DB: db},
+		Wishlists:       WishlistModel{DB: db},
+	}
+}
+
+func NewMemoryModels() Models {
+	store := newMemoryStore()
+	return Models{
+		Helmets:         MemoryHelmetModel{store: store},
+		HelmetRevisions: MemoryHelmetRevisionModel{store: store},
+		ChangeRequests:  MemoryHelmetChangeRequestModel{store: store},
+		Manufacturers:   MemoryManufacturerModel{store: store},
+		Images:          MemoryImageModel{store: store},
+		Permissions:     MemoryPermissionModel{store: store},
+		Prices:          MemoryPriceModel{store: store},
+		Reviews:         MemoryReviewModel{store: store},
+		Tokens:          MemoryTokenModel{store: store},
+		Users:           MemoryUserModel{store: store},
+		Variants:        MemoryVariantModel{store: store},
+		Wishlists:       MemoryWishlistModel{store: store},
 	}
 }
@@ -0,0 +1,58 @@
+package data
+
+import "sort"
+
+type MemoryPermissionModel struct {
+	store *memoryStore
+}
+
+func (m MemoryPermissionModel) GetAllForUser(userID int64) (Permissions, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	if _, ok := m.store.users[userID]; !ok {
+		return nil, nil
+	}
+
+	var ids []int64
+	for id := range m.store.usersPermissions[userID] {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var permissions Permissions
+	for _, id := range ids {
+		permissions = append(permissions, m.store.permissions[id])
+	}
+	return permissions, nil
+}
+
+func (m MemoryPermissionModel) AddForUser(userID int64, codes ...string) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if _, ok := m.store.users[userID]; !ok {
+		return foreignKeyViolation("users_permissions", "users_permissions_user_id_fkey")
+	}
+
+	var ids []int64
+	for id, code := range m.store.permissions {
+		for _, c := range codes {
+			if c == code {
+				if m.store.usersPermissions[userID][id] {
+					return uniqueViolation("users_permissions_pkey")
+				}
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+
+	if m.store.usersPermissions[userID] == nil {
+		m.store.usersPermissions[userID] = make(map[int64]bool)
+	}
+	for _, id := range ids {
+		m.store.usersPermissions[userID][id] = true
+	}
+	return nil
+}
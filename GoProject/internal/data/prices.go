@@ -0,0 +1,360 @@
+package data
+
+import (
+	"GoProject/internal/validator"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SupportedCurrencies lists the ISO 4217 codes prices can be set in. All of
+// them have two decimal places, which Money relies on.
+var SupportedCurrencies = []string{"EUR", "GBP", "KZT", "PLN", "RUB", "USD"}
+
+var moneyRX = regexp.MustCompile(`^[0-9]{1,10}(\.[0-9]{1,2})?$`)
+
+var ErrInvalidMoney = errors.New("invalid amount")
+
+// Money is an amount in minor units (cents). It is written to and read from
+// JSON as a decimal number with two decimal places, so amounts never go
+// through a float.
+type Money int64
+
+func ParseMoney(s string) (Money, error) {
+	if !moneyRX.MatchString(s) {
+		return 0, ErrInvalidMoney
+	}
+
+	units, cents, _ := strings.Cut(s, ".")
+	for len(cents) < 2 {
+		cents += "0"
+	}
+
+	m, err := strconv.ParseInt(units+cents, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidMoney
+	}
+	return Money(m), nil
+}
+
+func (m Money) String() string {
+	return fmt.Sprintf("%d.%02d", m/100, m%100)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts amounts both as JSON numbers and as strings.
+func (m *Money) UnmarshalJSON(js []byte) error {
+	s := strings.Trim(string(js), `"`)
+	money, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = money
+	return nil
+}
+
+// Price is the price of a helmet in one currency from EffectiveFrom until
+// the next price in the same currency takes effect. EffectiveTo is only set
+// in timelines.
+type Price struct {
+	ID            int64      `json:"id"`
+	HelmetID      int64      `json:"helmet_id"`
+	Currency      string     `json:"currency"`
+	Amount        Money      `json:"amount"`
+	EffectiveFrom time.Time  `json:"effective_from"`
+	EffectiveTo   *time.Time `json:"effective_to,omitempty"`
+	Current       bool       `json:"current"`
+}
+
+// PriceChange is an entry in the price history log. OldAmount is nil for
+// newly added prices and NewAmount is nil for removed ones.
+type PriceChange struct {
+	ID            int64     `json:"id"`
+	HelmetID      int64     `json:"helmet_id"`
+	Currency      string    `json:"currency"`
+	EffectiveFrom time.Time `json:"effective_from"`
+	OldAmount     *Money    `json:"old_amount"`
+	NewAmount     *Money    `json:"new_amount"`
+	UserID        int64     `json:"user_id,omitempty"`
+	ChangedAt     time.Time `json:"changed_at"`
+}
+
+func ValidatePrice(v *validator.Validator, price *Price) {
+	v.Check(validator.In(price.Currency, SupportedCurrencies...), "currency", "must be one of "+strings.Join(SupportedCurrencies, ", "))
+	v.Check(price.Amount > 0, "amount", "must be greater than zero")
+	v.Check(!price.EffectiveFrom.IsZero(), "effective_from", "must be provided")
+}
+
+type PriceModel struct {
+	DB *sql.DB
+}
+
+// Set saves the price of a helmet in a currency from the given date,
+// replacing any price already set for that exact date, and logs the change.
+// Setting a price to the amount it already has is not logged.
+func (m PriceModel) Set(price *Price, userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldAmount *Money
+	query := `
+		SELECT id, amount
+		FROM mhelmet_prices
+		WHERE helmet_id = $1 AND currency = $2 AND effective_from = $3
+		FOR UPDATE`
+
+	var amount Money
+	err = tx.QueryRowContext(ctx, query, price.HelmetID, price.Currency, price.EffectiveFrom).Scan(&price.ID, &amount)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		query = `
+			INSERT INTO mhelmet_prices (helmet_id, currency, amount, effective_from)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id`
+		err = tx.QueryRowContext(ctx, query, price.HelmetID, price.Currency, price.Amount, price.EffectiveFrom).Scan(&price.ID)
+	case err != nil:
+		return err
+	case amount == price.Amount:
+		return nil
+	default:
+		oldAmount = &amount
+		query = `
+			UPDATE mhelmet_prices
+			SET amount = $1
+			WHERE id = $2`
+		_, err = tx.ExecContext(ctx, query, price.Amount, price.ID)
+	}
+	if err != nil {
+		return translateError(err)
+	}
+
+	err = logPriceChange(ctx, tx, price, oldAmount, &price.Amount, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes a price from a helmet's timeline and logs the change.
+func (m PriceModel) Delete(helmetID, id int64, userID int64) error {
+	if helmetID < 1 || id < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		DELETE FROM mhelmet_prices
+		WHERE helmet_id = $1 AND id = $2
+		RETURNING currency, amount, effective_from`
+
+	price := Price{ID: id, HelmetID: helmetID}
+	err = tx.QueryRowContext(ctx, query, helmetID, id).Scan(&price.Currency, &price.Amount, &price.EffectiveFrom)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	err = logPriceChange(ctx, tx, &price, &price.Amount, nil, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func logPriceChange(ctx context.Context, tx *sql.Tx, price *Price, oldAmount, newAmount *Money, userID int64) error {
+	query := `
+		INSERT INTO mhelmet_price_changes (helmet_id, currency, effective_from, old_amount, new_amount, user_id)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0))`
+
+	args := []interface{}{price.HelmetID, price.Currency, price.EffectiveFrom, oldAmount, newAmount, userID}
+
+	_, err := tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+// GetTimeline returns the prices of a helmet ordered by currency and date,
+// each with the date the next price in its currency takes over. An empty
+// currency returns the timelines of all currencies.
+func (m PriceModel) GetTimeline(helmetID int64, currency string) ([]*Price, error) {
+	query := `
+		SELECT id, helmet_id, currency, amount, effective_from,
+			lead(effective_from) OVER (PARTITION BY currency ORDER BY effective_from)
+		FROM mhelmet_prices
+		WHERE helmet_id = $1 AND (currency = $2 OR $2 = '')
+		ORDER BY currency, effective_from`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, helmetID, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	now := time.Now()
+	prices := []*Price{}
+
+	for rows.Next() {
+		var price Price
+		err := rows.Scan(
+			&price.ID,
+			&price.HelmetID,
+			&price.Currency,
+			&price.Amount,
+			&price.EffectiveFrom,
+			&price.EffectiveTo,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		price.Current = !price.EffectiveFrom.After(now) && (price.EffectiveTo == nil || price.EffectiveTo.After(now))
+		prices = append(prices, &price)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return prices, nil
+}
+
+func (m PriceModel) GetHistory(helmetID int64, filters Filters) ([]*PriceChange, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, helmet_id, currency, effective_from, old_amount, new_amount, COALESCE(user_id, 0), changed_at
+		FROM mhelmet_price_changes
+		WHERE helmet_id = $1
+		ORDER BY %s %s, id ASC
+		LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, helmetID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	changes := []*PriceChange{}
+
+	for rows.Next() {
+		var change PriceChange
+		err := rows.Scan(
+			&totalRecords,
+			&change.ID,
+			&change.HelmetID,
+			&change.Currency,
+			&change.EffectiveFrom,
+			&change.OldAmount,
+			&change.NewAmount,
+			&change.UserID,
+			&change.ChangedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		changes = append(changes, &change)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return changes, metadata, nil
+}
+
+// currentPrice returns the SQL expression for the price of the helmet row in
+// currency that is in effect now.
+func currentPrice(currency string) string {
+	return `(
+		SELECT p.amount FROM mhelmet_prices AS p
+		WHERE p.helmet_id = mhelmets.id AND p.currency = ` + currency + ` AND p.effective_from <= NOW()
+		ORDER BY p.effective_from DESC
+		LIMIT 1)`
+}
+
+// attachPrices loads the prices currently in effect for helmets, in every
+// currency, and embeds them.
+func attachPrices(ctx context.Context, db *sql.DB, helmets ...*Helmet) error {
+	if len(helmets) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(helmets))
+	for i, helmet := range helmets {
+		ids[i] = helmet.ID
+	}
+
+	query := `
+		SELECT DISTINCT ON (helmet_id, currency) helmet_id, currency, amount
+		FROM mhelmet_prices
+		WHERE helmet_id = ANY($1) AND effective_from <= NOW()
+		ORDER BY helmet_id, currency, effective_from DESC`
+
+	rows, err := db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	prices := make(map[int64]map[string]Money)
+	for rows.Next() {
+		var helmetID int64
+		var currency string
+		var amount Money
+		if err := rows.Scan(&helmetID, &currency, &amount); err != nil {
+			return err
+		}
+		if prices[helmetID] == nil {
+			prices[helmetID] = make(map[string]Money)
+		}
+		prices[helmetID][currency] = amount
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, helmet := range helmets {
+		helmet.Prices = prices[helmet.ID]
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package data
+
+import (
+	"sort"
+	"time"
+)
+
+type MemoryPriceModel struct {
+	store *memoryStore
+}
+
+func (m MemoryPriceModel) Set(price *Price, userID int64) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if _, ok := m.store.helmets[price.HelmetID]; !ok {
+		return foreignKeyViolation("mhelmet_prices", "mhelmet_prices_helmet_id_fkey")
+	}
+
+	var oldAmount *Money
+	for _, current := range m.store.prices {
+		if current.HelmetID == price.HelmetID && current.Currency == price.Currency && current.EffectiveFrom.Equal(price.EffectiveFrom) {
+			if current.Amount == price.Amount {
+				price.ID = current.ID
+				return nil
+			}
+			amount := current.Amount
+			oldAmount = &amount
+			price.ID = current.ID
+			break
+		}
+	}
+
+	if price.ID == 0 {
+		m.store.pricesSeq++
+		price.ID = m.store.pricesSeq
+	}
+	m.store.prices[price.ID] = *price
+
+	newAmount := price.Amount
+	m.store.logPriceChange(price, oldAmount, &newAmount, userID)
+	return nil
+}
+
+func (m MemoryPriceModel) Delete(helmetID, id int64, userID int64) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	price, ok := m.store.prices[id]
+	if !ok || price.HelmetID != helmetID {
+		return ErrRecordNotFound
+	}
+	delete(m.store.prices, id)
+
+	oldAmount := price.Amount
+	m.store.logPriceChange(&price, &oldAmount, nil, userID)
+	return nil
+}
+
+func (m MemoryPriceModel) GetTimeline(helmetID int64, currency string) ([]*Price, error) {
+	m.store.mu.RLock()
+	prices := []*Price{}
+	for _, price := range m.store.prices {
+		if price.HelmetID != helmetID || (currency != "" && price.Currency != currency) {
+			continue
+		}
+		price := price
+		prices = append(prices, &price)
+	}
+	m.store.mu.RUnlock()
+
+	sort.Slice(prices, func(i, j int) bool {
+		if prices[i].Currency != prices[j].Currency {
+			return prices[i].Currency < prices[j].Currency
+		}
+		return prices[i].EffectiveFrom.Before(prices[j].EffectiveFrom)
+	})
+
+	now := time.Now()
+	for i, price := range prices {
+		if i+1 < len(prices) && prices[i+1].Currency == price.Currency {
+			effectiveTo := prices[i+1].EffectiveFrom
+			price.EffectiveTo = &effectiveTo
+		}
+		price.Current = !price.EffectiveFrom.After(now) && (price.EffectiveTo == nil || price.EffectiveTo.After(now))
+	}
+	return prices, nil
+}
+
+func (m MemoryPriceModel) GetHistory(helmetID int64, filters Filters) ([]*PriceChange, Metadata, error) {
+	m.store.mu.RLock()
+	changes := []*PriceChange{}
+	for _, change := range m.store.priceChanges {
+		if change.HelmetID != helmetID {
+			continue
+		}
+		change := change
+		changes = append(changes, &change)
+	}
+	m.store.mu.RUnlock()
+
+	// The history can only be sorted by id.
+	descending := filters.sortDirection() == "DESC"
+	sort.Slice(changes, func(i, j int) bool {
+		if descending {
+			return changes[i].ID > changes[j].ID
+		}
+		return changes[i].ID < changes[j].ID
+	})
+
+	start, end := pageBounds(len(changes), filters)
+	return changes[start:end], calculateMetadata(len(changes), filters.Page, filters.PageSize), nil
+}
+
+// logPriceChange is the in-memory counterpart of logPriceChange. The caller
+// must hold the store lock.
+func (s *memoryStore) logPriceChange(price *Price, oldAmount, newAmount *Money, userID int64) {
+	s.priceChangesSeq++
+	s.priceChanges[s.priceChangesSeq] = PriceChange{
+		ID:            s.priceChangesSeq,
+		HelmetID:      price.HelmetID,
+		Currency:      price.Currency,
+		EffectiveFrom: price.EffectiveFrom,
+		OldAmount:     oldAmount,
+		NewAmount:     newAmount,
+		UserID:        userID,
+		ChangedAt:     memoryNow(),
+	}
+}
+
+// currentPrice returns the price of a helmet in currency that is in effect
+// now. The caller must hold the store lock.
+func (s *memoryStore) currentPrice(helmetID int64, currency string) (Money, bool) {
+	now := time.Now()
+
+	var current *Price
+	for _, price := range s.prices {
+		price := price
+		if price.HelmetID != helmetID || price.Currency != currency || price.EffectiveFrom.After(now) {
+			continue
+		}
+		if current == nil || price.EffectiveFrom.After(current.EffectiveFrom) {
+			current = &price
+		}
+	}
+	if current == nil {
+		return 0, false
+	}
+	return current.Amount, true
+}
+
+// attachPrices embeds the prices currently in effect for the helmet. The
+// caller must hold the store lock.
+func (s *memoryStore) attachPrices(helmet *Helmet) {
+	helmet.Prices = nil
+	for _, currency := range SupportedCurrencies {
+		if amount, ok := s.currentPrice(helmet.ID, currency); ok {
+			if helmet.Prices == nil {
+				helmet.Prices = make(map[string]Money)
+			}
+			helmet.Prices[currency] = amount
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package data
+
+import (
+	"GoProject/internal/validator"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"strings"
+	"time"
+)
+
+var ErrDuplicateReview = errors.New("duplicate review")
+
+// Review is a user's opinion of a helmet. Each user can review a helmet
+// once, and only they can change or remove their review.
+type Review struct {
+	ID        int64     `json:"id"`
+	HelmetID  int64     `json:"helmet_id"`
+	UserID    int64     `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Rating    int16     `json:"rating"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body,omitempty"`
+	Version   int32     `json:"version"`
+}
+
+func ValidateReview(v *validator.Validator, review *Review) {
+	v.Check(review.Rating >= 1 && review.Rating <= 5, "rating", "must be between 1 and 5")
+	v.Check(strings.TrimSpace(review.Title) != "", "title", "must be provided")
+	v.Check(len(review.Title) <= 200, "title", "must not be more than 200 bytes long")
+	v.Check(len(review.Body) <= 10_000, "body", "must not be more than 10000 bytes long")
+}
+
+// averageRating is the SQL expression for the average rating of the helmet
+// row, rounded to two decimal places. Helmets without reviews rate 0.
+const averageRating = `COALESCE((
+	SELECT ROUND(AVG(r.rating), 2)::float8 FROM mhelmet_reviews AS r
+	WHERE r.helmet_id = mhelmets.id), 0)`
+
+type ReviewModel struct {
+	DB *sql.DB
+}
+
+func (m ReviewModel) Insert(review *Review) error {
+	query := `
+		INSERT INTO mhelmet_reviews (helmet_id, user_id, rating, title, body)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at, version`
+
+	args := []interface{}{review.HelmetID, review.UserID, review.Rating, review.Title, review.Body}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&review.ID, &review.CreatedAt, &review.UpdatedAt, &review.Version)
+	if err != nil {
+		return translateError(err)
+	}
+	return nil
+}
+
+func (m ReviewModel) GetAllForHelmet(helmetID int64, filters Filters) ([]*Review, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, helmet_id, user_id, created_at, updated_at, rating, title, body, version
+		FROM mhelmet_reviews
+		WHERE helmet_id = $1
+		ORDER BY %s %s, id ASC
+		LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, helmetID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	reviews := []*Review{}
+
+	for rows.Next() {
+		var review Review
+		err := rows.Scan(
+			&totalRecords,
+			&review.ID,
+			&review.HelmetID,
+			&review.UserID,
+			&review.CreatedAt,
+			&review.UpdatedAt,
+			&review.Rating,
+			&review.Title,
+			&review.Body,
+			&review.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		reviews = append(reviews, &review)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return reviews, metadata, nil
+}
+
+func (m ReviewModel) Get(helmetID, id int64) (*Review, error) {
+	if helmetID < 1 || id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, helmet_id, user_id, created_at, updated_at, rating, title, body, version
+		FROM mhelmet_reviews
+		WHERE helmet_id = $1 AND id = $2`
+
+	var review Review
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, helmetID, id).Scan(
+		&review.ID,
+		&review.HelmetID,
+		&review.UserID,
+		&review.CreatedAt,
+		&review.UpdatedAt,
+		&review.Rating,
+		&review.Title,
+		&review.Body,
+		&review.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &review, nil
+}
+
+func (m ReviewModel) Update(review *Review) error {
+	query := `
+		UPDATE mhelmet_reviews
+		SET rating = $1, title = $2, body = $3, updated_at = NOW(), version = version + 1
+		WHERE id = $4 AND version = $5
+		RETURNING updated_at, version`
+
+	args := []interface{}{review.Rating, review.Title, review.Body, review.ID, review.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&review.UpdatedAt, &review.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return translateError(err)
+		}
+	}
+	return nil
+}
+
+func (m ReviewModel) Delete(helmetID, id int64) error {
+	if helmetID < 1 || id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM mhelmet_reviews
+		WHERE helmet_id = $1 AND id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, helmetID, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// attachRatings loads the average rating and review count of helmets.
+func attachRatings(ctx context.Context, db *sql.DB, helmets ...*Helmet) error {
+	if len(helmets) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(helmets))
+	for i, helmet := range helmets {
+		ids[i] = helmet.ID
+		helmet.Rating, helmet.ReviewCount = 0, 0
+	}
+
+	query := `
+		SELECT helmet_id, ROUND(AVG(rating), 2)::float8, count(*)
+		FROM mhelmet_reviews
+		WHERE helmet_id = ANY($1)
+		GROUP BY helmet_id`
+
+	rows, err := db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	byID := make(map[int64]*Helmet, len(helmets))
+	for _, helmet := range helmets {
+		byID[helmet.ID] = helmet
+	}
+
+	for rows.Next() {
+		var helmetID int64
+		var rating float64
+		var count int32
+		if err := rows.Scan(&helmetID, &rating, &count); err != nil {
+			return err
+		}
+		byID[helmetID].Rating = rating
+		byID[helmetID].ReviewCount = count
+	}
+
+	return rows.Err()
+}
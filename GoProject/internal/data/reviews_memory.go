@@ -0,0 +1,123 @@
+package data
+
+import (
+	"math"
+	"sort"
+)
+
+type MemoryReviewModel struct {
+	store *memoryStore
+}
+
+func (m MemoryReviewModel) Insert(review *Review) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if _, ok := m.store.helmets[review.HelmetID]; !ok {
+		return foreignKeyViolation("mhelmet_reviews", "mhelmet_reviews_helmet_id_fkey")
+	}
+	for _, other := range m.store.reviews {
+		if other.HelmetID == review.HelmetID && other.UserID == review.UserID {
+			return uniqueViolation("mhelmet_reviews_helmet_id_user_id_idx")
+		}
+	}
+
+	m.store.reviewsSeq++
+	review.ID = m.store.reviewsSeq
+	review.CreatedAt = memoryNow()
+	review.UpdatedAt = review.CreatedAt
+	review.Version = 1
+	m.store.reviews[review.ID] = *review
+	return nil
+}
+
+func (m MemoryReviewModel) GetAllForHelmet(helmetID int64, filters Filters) ([]*Review, Metadata, error) {
+	column, direction := filters.sortColumn(), filters.sortDirection()
+
+	m.store.mu.RLock()
+	reviews := []*Review{}
+	for _, review := range m.store.reviews {
+		if review.HelmetID != helmetID {
+			continue
+		}
+		review := review
+		reviews = append(reviews, &review)
+	}
+	m.store.mu.RUnlock()
+
+	sort.Slice(reviews, func(i, j int) bool {
+		var c int
+		switch column {
+		case "id":
+			c = compareInt64(reviews[i].ID, reviews[j].ID)
+		case "rating":
+			c = compareInt64(int64(reviews[i].Rating), int64(reviews[j].Rating))
+		}
+		if direction == "DESC" {
+			c = -c
+		}
+		if c != 0 {
+			return c < 0
+		}
+		return reviews[i].ID < reviews[j].ID
+	})
+
+	start, end := pageBounds(len(reviews), filters)
+	return reviews[start:end], calculateMetadata(len(reviews), filters.Page, filters.PageSize), nil
+}
+
+func (m MemoryReviewModel) Get(helmetID, id int64) (*Review, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	review, ok := m.store.reviews[id]
+	if !ok || review.HelmetID != helmetID {
+		return nil, ErrRecordNotFound
+	}
+	return &review, nil
+}
+
+func (m MemoryReviewModel) Update(review *Review) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	current, ok := m.store.reviews[review.ID]
+	if !ok || current.Version != review.Version {
+		return ErrEditConflict
+	}
+
+	review.UpdatedAt = memoryNow()
+	review.Version++
+	m.store.reviews[review.ID] = *review
+	return nil
+}
+
+func (m MemoryReviewModel) Delete(helmetID, id int64) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	review, ok := m.store.reviews[id]
+	if !ok || review.HelmetID != helmetID {
+		return ErrRecordNotFound
+	}
+	delete(m.store.reviews, id)
+	return nil
+}
+
+// attachRatings sets the helmet's average rating, rounded to two decimal
+// places like the SQL model does, and review count. The caller must hold the
+// store lock.
+func (s *memoryStore) attachRatings(helmet *Helmet) {
+	var sum, count int32
+	for _, review := range s.reviews {
+		if review.HelmetID == helmet.ID {
+			sum += int32(review.Rating)
+			count++
+		}
+	}
+
+	helmet.Rating, helmet.ReviewCount = 0, count
+	if count > 0 {
+		helmet.Rating = math.Round(float64(sum)/float64(count)*100) / 100
+	}
+}
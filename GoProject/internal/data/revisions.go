@@ -0,0 +1,248 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+const (
+	RevisionActionUpdate             = "update"
+	RevisionActionRollback           = "rollback"
+	RevisionActionChangeRequest      = "change_request"
+	RevisionActionStatus             = "status"
+	RevisionActionScheduledPublish   = "scheduled_publish"
+	RevisionActionScheduledUnpublish = "scheduled_unpublish"
+)
+
+// HelmetSnapshot is the editable state of a helmet, as recorded in its
+// revision history.
+type HelmetSnapshot struct {
+	Name           string          `json:"name"`
+	Year           int32           `json:"year"`
+	Material       string          `json:"material"`
+	Ventilation    bool            `json:"ventilation"`
+	Protection     string          `json:"protection"`
+	Certifications []Certification `json:"certifications"`
+	Weight         float64         `json:"weight"`
+	SunProtection  bool            `json:"sun_protection"`
+	ManufacturerID int64           `json:"manufacturer_id"`
+}
+
+func NewHelmetSnapshot(helmet *Helmet) HelmetSnapshot {
+	return HelmetSnapshot{
+		Name:           helmet.Name,
+		Year:           helmet.Year,
+		Material:       helmet.Material,
+		Ventilation:    helmet.Ventilation,
+		Protection:     helmet.Protection,
+		Certifications: append([]Certification{}, helmet.Certifications...),
+		Weight:         helmet.Weight,
+		SunProtection:  helmet.SunProtection,
+		ManufacturerID: helmet.ManufacturerID,
+	}
+}
+
+// Apply copies the snapshot onto helmet, leaving its id and version alone.
+func (s HelmetSnapshot) Apply(helmet *Helmet) {
+	helmet.Name = s.Name
+	helmet.Year = s.Year
+	helmet.Material = s.Material
+	helmet.Ventilation = s.Ventilation
+	helmet.Protection = s.Protection
+	// Snapshots recorded before certifications were tracked have none, and
+	// leave the helmet's certifications alone.
+	if s.Certifications != nil {
+		helmet.Certifications = append([]Certification{}, s.Certifications...)
+	}
+	helmet.Weight = s.Weight
+	helmet.SunProtection = s.SunProtection
+	helmet.ManufacturerID = s.ManufacturerID
+	helmet.Manufacturer = nil
+}
+
+type FieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// Diff returns the fields that differ between s and other, keyed by their
+// JSON names.
+func (s HelmetSnapshot) Diff(other HelmetSnapshot) map[string]FieldChange {
+	from, to := s.fields(), other.fields()
+
+	changes := make(map[string]FieldChange)
+	for key, value := range from {
+		if !reflect.DeepEqual(value, to[key]) {
+			changes[key] = FieldChange{From: value, To: to[key]}
+		}
+	}
+	return changes
+}
+
+func (s HelmetSnapshot) fields() map[string]interface{} {
+	js, err := json.Marshal(s)
+	if err != nil {
+		panic(err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(js, &fields); err != nil {
+		panic(err)
+	}
+	return fields
+}
+
+// HelmetRevision records a single change made to a helmet. Version is the
+// helmet version the change produced, and Status the status it left the
+// helmet in.
+type HelmetRevision struct {
+	ID        int64                  `json:"id"`
+	HelmetID  int64                  `json:"helmet_id"`
+	Version   int32                  `json:"version"`
+	Action    string                 `json:"action"`
+	Status    string                 `json:"status"`
+	UserID    int64                  `json:"user_id,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+	Before    HelmetSnapshot         `json:"before"`
+	After     HelmetSnapshot         `json:"after"`
+	Changes   map[string]FieldChange `json:"changes"`
+}
+
+type HelmetRevisionModel struct {
+	DB *sql.DB
+}
+
+// insertRevision records a change inside the transaction that made it, so
+// that no update can be saved without its revision.
+func insertRevision(ctx context.Context, tx *sql.Tx, revision *HelmetRevision) error {
+	before, err := json.Marshal(revision.Before)
+	if err != nil {
+		return err
+	}
+	after, err := json.Marshal(revision.After)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO mhelmet_revisions (helmet_id, version, action, status, user_id, before, after)
+		VALUES ($1, $2, $3, $4, NULLIF($5, 0), $6, $7)
+		RETURNING id, created_at`
+
+	args := []interface{}{revision.HelmetID, revision.Version, revision.Action, revision.Status, revision.UserID, before, after}
+
+	return tx.QueryRowContext(ctx, query, args...).Scan(&revision.ID, &revision.CreatedAt)
+}
+
+func (m HelmetRevisionModel) GetAllForHelmet(helmetID int64, filters Filters) ([]*HelmetRevision, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, helmet_id, version, action, status, COALESCE(user_id, 0), created_at, before, after
+		FROM mhelmet_revisions
+		WHERE helmet_id = $1
+		ORDER BY %s %s, id ASC
+		LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, helmetID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	revisions := []*HelmetRevision{}
+
+	for rows.Next() {
+		var revision HelmetRevision
+		var before, after []byte
+		err := rows.Scan(
+			&totalRecords,
+			&revision.ID,
+			&revision.HelmetID,
+			&revision.Version,
+			&revision.Action,
+			&revision.Status,
+			&revision.UserID,
+			&revision.CreatedAt,
+			&before,
+			&after,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		if err := revision.unmarshalSnapshots(before, after); err != nil {
+			return nil, Metadata{}, err
+		}
+
+		revisions = append(revisions, &revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return revisions, metadata, nil
+}
+
+func (m HelmetRevisionModel) Get(helmetID, id int64) (*HelmetRevision, error) {
+	if helmetID < 1 || id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, helmet_id, version, action, status, COALESCE(user_id, 0), created_at, before, after
+		FROM mhelmet_revisions
+		WHERE helmet_id = $1 AND id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var revision HelmetRevision
+	var before, after []byte
+
+	err := m.DB.QueryRowContext(ctx, query, helmetID, id).Scan(
+		&revision.ID,
+		&revision.HelmetID,
+		&revision.Version,
+		&revision.Action,
+		&revision.Status,
+		&revision.UserID,
+		&revision.CreatedAt,
+		&before,
+		&after,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if err := revision.unmarshalSnapshots(before, after); err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+func (r *HelmetRevision) unmarshalSnapshots(before, after []byte) error {
+	if err := json.Unmarshal(before, &r.Before); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(after, &r.After); err != nil {
+		return err
+	}
+	r.Changes = r.Before.Diff(r.After)
+	return nil
+}
@@ -0,0 +1,63 @@
+package data
+
+import "sort"
+
+type MemoryHelmetRevisionModel struct {
+	store *memoryStore
+}
+
+func (m MemoryHelmetRevisionModel) GetAllForHelmet(helmetID int64, filters Filters) ([]*HelmetRevision, Metadata, error) {
+	m.store.mu.RLock()
+	matched := []*HelmetRevision{}
+	for _, revision := range m.store.revisions {
+		if revision.HelmetID != helmetID {
+			continue
+		}
+		revision := revision
+		revision.Changes = revision.Before.Diff(revision.After)
+		matched = append(matched, &revision)
+	}
+	m.store.mu.RUnlock()
+
+	// Revisions can only be sorted by id.
+	descending := filters.sortDirection() == "DESC"
+	sort.Slice(matched, func(i, j int) bool {
+		if descending {
+			return matched[i].ID > matched[j].ID
+		}
+		return matched[i].ID < matched[j].ID
+	})
+
+	start, end := pageBounds(len(matched), filters)
+	return matched[start:end], calculateMetadata(len(matched), filters.Page, filters.PageSize), nil
+}
+
+func (m MemoryHelmetRevisionModel) Get(helmetID, id int64) (*HelmetRevision, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	revision, ok := m.store.revisions[id]
+	if !ok || revision.HelmetID != helmetID {
+		return nil, ErrRecordNotFound
+	}
+	revision.Changes = revision.Before.Diff(revision.After)
+	return &revision, nil
+}
+
+// insertRevision records a change that took a helmet from before to after,
+// which is at the version the change produced. The caller must hold the
+// store's lock.
+func (s *memoryStore) insertRevision(before, after *Helmet, action string, userID int64) {
+	s.revisionsSeq++
+	s.revisions[s.revisionsSeq] = HelmetRevision{
+		ID:        s.revisionsSeq,
+		HelmetID:  after.ID,
+		Version:   after.Version,
+		Action:    action,
+		Status:    after.Status,
+		UserID:    userID,
+		CreatedAt: memoryNow(),
+		Before:    NewHelmetSnapshot(before),
+		After:     NewHelmetSnapshot(after),
+	}
+}
@@ -0,0 +1,235 @@
+package data
+
+import (
+	"GoProject/internal/validator"
+	"strings"
+	"unicode"
+)
+
+// SearchQuery is a parsed websearch-style query: unquoted words are ANDed,
+// "quoted text" is a phrase, OR separates alternatives and a leading - excludes
+// a term. Every word is matched as a prefix.
+type SearchQuery struct {
+	Raw    string
+	groups []searchGroup
+}
+
+type searchGroup []searchTerm
+
+type searchTerm struct {
+	words   []string
+	negated bool
+}
+
+func ParseSearchQuery(q string) SearchQuery {
+	s := SearchQuery{Raw: q}
+	var group searchGroup
+
+	runes := []rune(q)
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		negated := false
+		if runes[i] == '-' {
+			negated = true
+			i++
+		}
+
+		var chunk string
+		phrase := false
+		if i < len(runes) && runes[i] == '"' {
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			chunk = string(runes[i+1 : end])
+			phrase = true
+			i = end + 1
+		} else {
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && runes[end] != '"' {
+				end++
+			}
+			chunk = string(runes[i:end])
+			i = end
+		}
+
+		if !phrase && !negated && strings.EqualFold(chunk, "or") {
+			if len(group) > 0 {
+				s.groups = append(s.groups, group)
+				group = nil
+			}
+			continue
+		}
+
+		words := searchWords(chunk)
+		if len(words) == 0 {
+			continue
+		}
+		group = append(group, searchTerm{words: words, negated: negated})
+	}
+
+	if len(group) > 0 {
+		s.groups = append(s.groups, group)
+	}
+	return s
+}
+
+func ValidateSearchQuery(v *validator.Validator, s SearchQuery) {
+	v.Check(len(s.Raw) <= 500, "q", "must not be more than 500 bytes long")
+	v.Check(len(s.groups) > 0, "q", "must contain at least one search term")
+	for _, group := range s.groups {
+		v.Check(group.hasPositiveTerm(), "q", "every alternative must contain a term that is not excluded")
+	}
+}
+
+func (s SearchQuery) IsEmpty() bool {
+	return len(s.groups) == 0
+}
+
+// tsquery renders the query for to_tsquery('simple', ...). Words are quoted
+// so that the database parser splits them exactly like to_tsvector does.
+func (s SearchQuery) tsquery() string {
+	alternatives := make([]string, 0, len(s.groups))
+	for _, group := range s.groups {
+		terms := make([]string, 0, len(group))
+		for _, term := range group {
+			words := make([]string, 0, len(term.words))
+			for _, word := range term.words {
+				words = append(words, tsqueryLexeme(word))
+			}
+			rendered := "(" + strings.Join(words, " <-> ") + ")"
+			if term.negated {
+				rendered = "!" + rendered
+			}
+			terms = append(terms, rendered)
+		}
+		alternatives = append(alternatives, "("+strings.Join(terms, " & ")+")")
+	}
+	return strings.Join(alternatives, " | ")
+}
+
+// anyWordTSQuery matches a row when any non-excluded word matches. Every row
+// matching tsquery() also matches this one, and since it can be evaluated
+// against each column separately it lets the per-column GIN indexes narrow
+// the candidates before the full query is checked.
+func (s SearchQuery) anyWordTSQuery() string {
+	var words []string
+	for _, group := range s.groups {
+		for _, term := range group {
+			if term.negated {
+				continue
+			}
+			for _, word := range term.words {
+				words = append(words, tsqueryLexeme(word))
+			}
+		}
+	}
+	return strings.Join(words, " | ")
+}
+
+func (g searchGroup) hasPositiveTerm() bool {
+	for _, term := range g {
+		if !term.negated {
+			return true
+		}
+	}
+	return false
+}
+
+func tsqueryLexeme(word string) string {
+	word = strings.ReplaceAll(word, `\`, `\\`)
+	word = strings.ReplaceAll(word, `'`, `''`)
+	return "'" + word + "':*"
+}
+
+// searchWords splits text into lowercase words the way the 'simple' text
+// search configuration does, keeping dotted numbers such as 22.05 together.
+func searchWords(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.'
+	})
+
+	words := make([]string, 0, len(fields))
+	for _, field := range fields {
+		field = strings.Trim(field, ".")
+		if field == "" {
+			continue
+		}
+		if strings.IndexFunc(field, unicode.IsLetter) >= 0 {
+			for _, part := range strings.Split(field, ".") {
+				if part != "" {
+					words = append(words, part)
+				}
+			}
+			continue
+		}
+		words = append(words, field)
+	}
+	return words
+}
+
+// match reports whether the query matches the given columns and returns a
+// relevance score. Columns are given in weight order (name, material,
+// protection) and are treated as one document, like the concatenated tsvector
+// used by the SQL query.
+func (s SearchQuery) match(columns ...string) (bool, float64) {
+	weights := []float64{1.0, 0.4, 0.2}
+
+	var doc []string
+	var docWeights []float64
+	for i, column := range columns {
+		for _, word := range searchWords(column) {
+			doc = append(doc, word)
+			docWeights = append(docWeights, weights[i])
+		}
+	}
+
+	matched := false
+	score := 0.0
+	for _, group := range s.groups {
+		groupScore := 0.0
+		ok := true
+		for _, term := range group {
+			found, termScore := term.find(doc, docWeights)
+			if found == term.negated {
+				ok = false
+				break
+			}
+			groupScore += termScore
+		}
+		if ok {
+			matched = true
+			if groupScore > score {
+				score = groupScore
+			}
+		}
+	}
+
+	if !matched {
+		return false, 0
+	}
+	return true, score / float64(len(doc)+1)
+}
+
+func (t searchTerm) find(doc []string, weights []float64) (bool, float64) {
+	found := false
+	score := 0.0
+	for start := 0; start+len(t.words) <= len(doc); start++ {
+		ok := true
+		for i, word := range t.words {
+			if !strings.HasPrefix(doc[start+i], word) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			found = true
+			score += weights[start]
+		}
+	}
+	return found, score
+}
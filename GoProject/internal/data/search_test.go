@@ -0,0 +1,148 @@
+package data
+
+import (
+	"GoProject/internal/validator"
+	"reflect"
+	"testing"
+)
+
+func TestSearchWords(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Shoei RF-1400", []string{"shoei", "rf", "1400"}},
+		{"ECE 22.05", []string{"ece", "22.05"}},
+		{"v1.2", []string{"v1", "2"}},
+		{"...22.06...", []string{"22.06"}},
+		{"Kask d'Arai", []string{"kask", "d", "arai"}},
+		{"Casque intégral", []string{"casque", "intégral"}},
+		{"  --  ", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got := searchWords(tt.text)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		q           string
+		wantTSQuery string
+		wantAnyWord string
+	}{
+		{"shoei", "(('shoei':*))", "'shoei':*"},
+		{"shoei carbon", "(('shoei':*) & ('carbon':*))", "'shoei':* | 'carbon':*"},
+		{`"full face" carbon`, "(('full':* <-> 'face':*) & ('carbon':*))", "'full':* | 'face':* | 'carbon':*"},
+		{"shoei or arai", "(('shoei':*)) | (('arai':*))", "'shoei':* | 'arai':*"},
+		{"shoei OR", "(('shoei':*))", "'shoei':*"},
+		{`"or" shoei`, "(('or':*) & ('shoei':*))", "'or':* | 'shoei':*"},
+		{"carbon -modular", "(('carbon':*) & !('modular':*))", "'carbon':*"},
+		{`carbon -"open face"`, "(('carbon':*) & !('open':* <-> 'face':*))", "'carbon':*"},
+		{`"unterminated phrase`, "(('unterminated':* <-> 'phrase':*))", "'unterminated':* | 'phrase':*"},
+		{`it's`, "(('it':* <-> 's':*))", "'it':* | 's':*"},
+		{`back\slash`, "(('back':* <-> 'slash':*))", "'back':* | 'slash':*"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.q, func(t *testing.T) {
+			s := ParseSearchQuery(tt.q)
+			if got := s.tsquery(); got != tt.wantTSQuery {
+				t.Errorf("got tsquery %q, want %q", got, tt.wantTSQuery)
+			}
+			if got := s.anyWordTSQuery(); got != tt.wantAnyWord {
+				t.Errorf("got any word tsquery %q, want %q", got, tt.wantAnyWord)
+			}
+		})
+	}
+}
+
+func TestTSQueryLexeme(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{"shoei", `'shoei':*`},
+		{"o'neal", `'o''neal':*`},
+		{`a\b`, `'a\\b':*`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.word, func(t *testing.T) {
+			if got := tsqueryLexeme(tt.word); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSearchQuery(t *testing.T) {
+	tests := []struct {
+		q     string
+		valid bool
+	}{
+		{"shoei", true},
+		{"carbon -modular", true},
+		{"", false},
+		{"  \"\" or ", false},
+		{"-modular", false},
+		{"carbon or -modular", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.q, func(t *testing.T) {
+			v := validator.New()
+			ValidateSearchQuery(v, ParseSearchQuery(tt.q))
+			if v.Valid() != tt.valid {
+				t.Errorf("got valid %t, want %t: %v", v.Valid(), tt.valid, v.Errors)
+			}
+		})
+	}
+}
+
+func TestSearchQueryMatch(t *testing.T) {
+	columns := []string{"Shoei RF-1400", "carbon fibre", "full face"}
+
+	tests := []struct {
+		q    string
+		want bool
+	}{
+		{"shoei", true},
+		{"sho", true},
+		{"hoei", false},
+		{"rf 1400", true},
+		{`"full face"`, true},
+		{`"face full"`, false},
+		{`"shoei rf"`, true},
+		{"shoei -carbon", false},
+		{"shoei -modular", true},
+		{"arai or shoei", true},
+		{"arai or modular", false},
+		{"shoei arai", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.q, func(t *testing.T) {
+			got, _ := ParseSearchQuery(tt.q).match(columns...)
+			if got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchQueryMatchRanksNameAboveOtherColumns(t *testing.T) {
+	q := ParseSearchQuery("carbon")
+
+	_, inName := q.match("Carbon Pro", "fibreglass", "full face")
+	_, inMaterial := q.match("Pro", "carbon fibreglass", "full face")
+
+	if inName <= inMaterial {
+		t.Errorf("got score %v for a name match and %v for a material match, want the name match to rank higher", inName, inMaterial)
+	}
+}
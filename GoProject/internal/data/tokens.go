@@ -68,7 +68,7 @@ func (m TokenModel) Insert(token *Token) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 	_, err := m.DB.ExecContext(ctx, query, args...)
-	return err
+	return translateError(err)
 }
 
 func (m TokenModel) DeleteAllForUser(scope string, userID int64) error {
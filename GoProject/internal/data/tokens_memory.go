@@ -0,0 +1,60 @@
+package data
+
+import "time"
+
+type MemoryTokenModel struct {
+	store *memoryStore
+}
+
+func (m MemoryTokenModel) New(userID int64, ttl time.Duration, scope string) (*Token, error) {
+	token, err := generateToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+	err = m.Insert(token)
+	return token, err
+}
+
+func (m MemoryTokenModel) Insert(token *Token) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if _, ok := m.store.users[token.UserID]; !ok {
+		return foreignKeyViolation("tokens", "tokens_user_id_fkey")
+	}
+	if _, ok := m.store.tokens[string(token.Hash)]; ok {
+		return uniqueViolation("tokens_pkey")
+	}
+
+	m.store.purgeExpiredTokens()
+
+	stored := *token
+	stored.Plaintext = ""
+	stored.Hash = append([]byte(nil), token.Hash...)
+	stored.Expiry = token.Expiry.Truncate(time.Second)
+	m.store.tokens[string(stored.Hash)] = stored
+	return nil
+}
+
+func (m MemoryTokenModel) DeleteAllForUser(scope string, userID int64) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	for hash, token := range m.store.tokens {
+		if token.Scope == scope && token.UserID == userID {
+			delete(m.store.tokens, hash)
+		}
+	}
+	return nil
+}
+
+// purgeExpiredTokens drops tokens that can no longer be used, so a long
+// running server doesn't accumulate them. The caller must hold the store lock.
+func (s *memoryStore) purgeExpiredTokens() {
+	now := time.Now()
+	for hash, token := range s.tokens {
+		if !token.Expiry.After(now) {
+			delete(s.tokens, hash)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package data
+
+import (
+	"crypto/sha256"
+	"strings"
+	"time"
+)
+
+type MemoryUserModel struct {
+	store *memoryStore
+}
+
+func (m MemoryUserModel) Insert(user *User) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if m.store.emailTaken(user.Email, 0) {
+		return uniqueViolation("users_email_key")
+	}
+
+	m.store.usersSeq++
+	user.ID = m.store.usersSeq
+	user.CreatedAt = memoryNow()
+	user.Version = 1
+	m.store.users[user.ID] = m.store.storedUser(user)
+	return nil
+}
+
+func (m MemoryUserModel) GetByEmail(email string) (*User, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	for _, user := range m.store.users {
+		if strings.EqualFold(user.Email, email) {
+			return &user, nil
+		}
+	}
+	return nil, ErrRecordNotFound
+}
+
+func (m MemoryUserModel) Update(user *User) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if m.store.emailTaken(user.Email, user.ID) {
+		return uniqueViolation("users_email_key")
+	}
+
+	current, ok := m.store.users[user.ID]
+	if !ok || current.Version != user.Version {
+		return ErrEditConflict
+	}
+
+	user.Version++
+	user.CreatedAt = current.CreatedAt
+	m.store.users[user.ID] = m.store.storedUser(user)
+	return nil
+}
+
+func (m MemoryUserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+	now := time.Now()
+
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	token, ok := m.store.tokens[string(tokenHash[:])]
+	if !ok || token.Scope != tokenScope || !token.Expiry.After(now) {
+		return nil, ErrRecordNotFound
+	}
+
+	user, ok := m.store.users[token.UserID]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	return &user, nil
+}
+
+// emailTaken mirrors the citext UNIQUE constraint on users.email. The caller
+// must hold the store lock.
+func (s *memoryStore) emailTaken(email string, exceptID int64) bool {
+	for id, user := range s.users {
+		if id != exceptID && strings.EqualFold(user.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// storedUser returns the copy of user that is kept in the store. Only the
+// password hash is persisted, as it is in the users table.
+func (s *memoryStore) storedUser(user *User) User {
+	stored := *user
+	stored.Password = password{hash: append([]byte(nil), user.Password.hash...)}
+	return stored
+}
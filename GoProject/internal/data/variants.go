@@ -0,0 +1,249 @@
+package data
+
+import (
+	"GoProject/internal/validator"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	ErrDuplicateSKU     = errors.New("duplicate sku")
+	ErrDuplicateVariant = errors.New("duplicate variant")
+)
+
+// VariantSizes lists the helmet sizes from smallest to largest. Variants are
+// listed in this order.
+var VariantSizes = []string{"XXS", "XS", "S", "M", "L", "XL", "XXL", "XXXL"}
+
+var SKURX = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Variant is a size and colour a helmet is sold in. Weight overrides the
+// weight of the helmet when the variant differs from it, and is nil otherwise.
+type Variant struct {
+	ID        int64     `json:"id"`
+	HelmetID  int64     `json:"helmet_id"`
+	CreatedAt time.Time `json:"-"`
+	Size      string    `json:"size"`
+	Colour    string    `json:"colour,omitempty"`
+	SKU       string    `json:"sku"`
+	Weight    *float64  `json:"weight,omitempty"`
+	Stock     int32     `json:"stock"`
+	Version   int32     `json:"version"`
+}
+
+func ValidateVariant(v *validator.Validator, variant *Variant) {
+	v.Check(variant.Size != "", "size", "must be provided")
+	v.Check(variant.Size == "" || validator.In(variant.Size, VariantSizes...), "size", "must be one of "+strings.Join(VariantSizes, ", "))
+	v.Check(len(variant.Colour) <= 100, "colour", "must not be more than 100 bytes long")
+	v.Check(variant.SKU != "", "sku", "must be provided")
+	v.Check(len(variant.SKU) <= 64, "sku", "must not be more than 64 bytes long")
+	v.Check(variant.SKU == "" || validator.Matches(variant.SKU, SKURX), "sku", "must only contain letters, digits, '.', '_' and '-'")
+	if variant.Weight != nil {
+		v.Check(*variant.Weight >= 0.5 && *variant.Weight <= 2.5, "weight", "must be between 0.5 and 2.5")
+	}
+	v.Check(variant.Stock >= 0, "stock", "must not be negative")
+}
+
+type VariantModel struct {
+	DB *sql.DB
+}
+
+func (m VariantModel) Insert(variant *Variant) error {
+	query := `
+		INSERT INTO mhelmet_variants (helmet_id, size, colour, sku, weight, stock)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, version`
+
+	args := []interface{}{variant.HelmetID, variant.Size, variant.Colour, variant.SKU, variant.Weight, variant.Stock}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&variant.ID, &variant.CreatedAt, &variant.Version)
+	if err != nil {
+		return translateError(err)
+	}
+	return nil
+}
+
+// GetAllForHelmet returns every variant of a helmet, ordered by size and
+// then colour.
+func (m VariantModel) GetAllForHelmet(helmetID int64) ([]*Variant, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return queryVariants(ctx, m.DB, []int64{helmetID})
+}
+
+func (m VariantModel) Get(helmetID, id int64) (*Variant, error) {
+	if helmetID < 1 || id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, helmet_id, created_at, size, colour, sku, weight, stock, version
+		FROM mhelmet_variants
+		WHERE helmet_id = $1 AND id = $2`
+
+	var variant Variant
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, helmetID, id).Scan(
+		&variant.ID,
+		&variant.HelmetID,
+		&variant.CreatedAt,
+		&variant.Size,
+		&variant.Colour,
+		&variant.SKU,
+		&variant.Weight,
+		&variant.Stock,
+		&variant.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &variant, nil
+}
+
+func (m VariantModel) Update(variant *Variant) error {
+	query := `
+		UPDATE mhelmet_variants
+		SET size = $1, colour = $2, sku = $3, weight = $4, stock = $5, version = version + 1
+		WHERE id = $6 AND helmet_id = $7 AND version = $8
+		RETURNING version`
+
+	args := []interface{}{
+		variant.Size,
+		variant.Colour,
+		variant.SKU,
+		variant.Weight,
+		variant.Stock,
+		variant.ID,
+		variant.HelmetID,
+		variant.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&variant.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return translateError(err)
+		}
+	}
+	return nil
+}
+
+func (m VariantModel) Delete(helmetID, id int64) error {
+	if helmetID < 1 || id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM mhelmet_variants
+		WHERE helmet_id = $1 AND id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, helmetID, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func queryVariants(ctx context.Context, db *sql.DB, helmetIDs []int64) ([]*Variant, error) {
+	query := `
+		SELECT id, helmet_id, created_at, size, colour, sku, weight, stock, version
+		FROM mhelmet_variants
+		WHERE helmet_id = ANY($1)
+		ORDER BY helmet_id, array_position($2::text[], size), LOWER(colour), id`
+
+	rows, err := db.QueryContext(ctx, query, pq.Array(helmetIDs), pq.Array(VariantSizes))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	variants := []*Variant{}
+
+	for rows.Next() {
+		var variant Variant
+		err := rows.Scan(
+			&variant.ID,
+			&variant.HelmetID,
+			&variant.CreatedAt,
+			&variant.Size,
+			&variant.Colour,
+			&variant.SKU,
+			&variant.Weight,
+			&variant.Stock,
+			&variant.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		variants = append(variants, &variant)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return variants, nil
+}
+
+// attachVariants loads the variants of helmets and embeds them.
+func attachVariants(ctx context.Context, db *sql.DB, helmets ...*Helmet) error {
+	if len(helmets) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(helmets))
+	for i, helmet := range helmets {
+		ids[i] = helmet.ID
+	}
+
+	variants, err := queryVariants(ctx, db, ids)
+	if err != nil {
+		return err
+	}
+
+	byHelmet := make(map[int64][]*Variant)
+	for _, variant := range variants {
+		byHelmet[variant.HelmetID] = append(byHelmet[variant.HelmetID], variant)
+	}
+
+	for _, helmet := range helmets {
+		helmet.Variants = byHelmet[helmet.ID]
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package data
+
+import (
+	"sort"
+	"strings"
+)
+
+type MemoryVariantModel struct {
+	store *memoryStore
+}
+
+func (m MemoryVariantModel) Insert(variant *Variant) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if _, ok := m.store.helmets[variant.HelmetID]; !ok {
+		return foreignKeyViolation("mhelmet_variants", "mhelmet_variants_helmet_id_fkey")
+	}
+	if err := m.store.checkVariant(variant); err != nil {
+		return err
+	}
+
+	m.store.variantsSeq++
+	variant.ID = m.store.variantsSeq
+	variant.CreatedAt = memoryNow()
+	variant.Version = 1
+	m.store.variants[variant.ID] = *variant
+	return nil
+}
+
+func (m MemoryVariantModel) GetAllForHelmet(helmetID int64) ([]*Variant, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	return m.store.variantsForHelmet(helmetID), nil
+}
+
+// variantsForHelmet returns copies of the helmet's variants in listing
+// order. The caller must hold the store lock.
+func (s *memoryStore) variantsForHelmet(helmetID int64) []*Variant {
+	variants := []*Variant{}
+	for _, variant := range s.variants {
+		if variant.HelmetID != helmetID {
+			continue
+		}
+		variant := variant
+		variants = append(variants, &variant)
+	}
+
+	sort.Slice(variants, func(i, j int) bool {
+		a, b := variants[i], variants[j]
+		if sa, sb := sizeRank(a.Size), sizeRank(b.Size); sa != sb {
+			return sa < sb
+		}
+		if c := strings.Compare(strings.ToLower(a.Colour), strings.ToLower(b.Colour)); c != 0 {
+			return c < 0
+		}
+		return a.ID < b.ID
+	})
+	return variants
+}
+
+// attachVariants embeds the helmet's variants. The caller must hold the
+// store lock.
+func (s *memoryStore) attachVariants(helmet *Helmet) {
+	helmet.Variants = nil
+	if variants := s.variantsForHelmet(helmet.ID); len(variants) > 0 {
+		helmet.Variants = variants
+	}
+}
+
+func (m MemoryVariantModel) Get(helmetID, id int64) (*Variant, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	variant, ok := m.store.variants[id]
+	if !ok || variant.HelmetID != helmetID {
+		return nil, ErrRecordNotFound
+	}
+	return &variant, nil
+}
+
+func (m MemoryVariantModel) Update(variant *Variant) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	current, ok := m.store.variants[variant.ID]
+	if !ok || current.HelmetID != variant.HelmetID || current.Version != variant.Version {
+		return ErrEditConflict
+	}
+	if err := m.store.checkVariant(variant); err != nil {
+		return err
+	}
+
+	variant.Version++
+	variant.CreatedAt = current.CreatedAt
+	m.store.variants[variant.ID] = *variant
+	return nil
+}
+
+func (m MemoryVariantModel) Delete(helmetID, id int64) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	variant, ok := m.store.variants[id]
+	if !ok || variant.HelmetID != helmetID {
+		return ErrRecordNotFound
+	}
+	delete(m.store.variants, id)
+	return nil
+}
+
+// checkVariant mirrors the unique indexes on mhelmet_variants. The caller
+// must hold the store lock.
+func (s *memoryStore) checkVariant(variant *Variant) error {
+	for _, other := range s.variants {
+		if other.ID == variant.ID {
+			continue
+		}
+		if other.SKU == variant.SKU {
+			return uniqueViolation("mhelmet_variants_sku_idx")
+		}
+		if other.HelmetID == variant.HelmetID && other.Size == variant.Size && strings.EqualFold(other.Colour, variant.Colour) {
+			return uniqueViolation("mhelmet_variants_helmet_id_size_colour_idx")
+		}
+	}
+	return nil
+}
+
+// hasVariant is the in-memory counterpart of the variant conditions in
+// HelmetFilter.where. The caller must hold the store lock.
+func (s *memoryStore) hasVariant(helmetID int64, size string, inStock bool) bool {
+	for _, variant := range s.variants {
+		if variant.HelmetID != helmetID {
+			continue
+		}
+		if (size == "" || variant.Size == size) && (!inStock || variant.Stock > 0) {
+			return true
+		}
+	}
+	return false
+}
+
+func sizeRank(size string) int {
+	for i, s := range VariantSizes {
+		if s == size {
+			return i
+		}
+	}
+	return len(VariantSizes)
+}
@@ -0,0 +1,371 @@
+package data
+
+import (
+	"GoProject/internal/validator"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"strings"
+	"time"
+)
+
+// FavouritesName is the name of the default wishlist every user has.
+const FavouritesName = "Favourites"
+
+var ErrDuplicateWishlist = errors.New("duplicate wishlist name")
+
+// Wishlist is a list of helmets a user has bookmarked. Every user has one
+// default list, their favourites, which is created the first time it's
+// needed and can be neither renamed nor deleted.
+type Wishlist struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	Name      string    `json:"name"`
+	Default   bool      `json:"default"`
+	ItemCount int32     `json:"item_count"`
+	Version   int32     `json:"version"`
+}
+
+// WishlistItem is a helmet on a wishlist. Helmets in the trash are left out
+// of wishlists until they are restored.
+type WishlistItem struct {
+	HelmetID int64     `json:"helmet_id"`
+	AddedAt  time.Time `json:"added_at"`
+	Helmet   *Helmet   `json:"helmet"`
+}
+
+func ValidateWishlist(v *validator.Validator, wishlist *Wishlist) {
+	v.Check(strings.TrimSpace(wishlist.Name) != "", "name", "must be provided")
+	v.Check(len(wishlist.Name) <= 100, "name", "must not be more than 100 bytes long")
+	v.Check(!strings.EqualFold(strings.TrimSpace(wishlist.Name), FavouritesName), "name", "is reserved for the favourites list")
+}
+
+type WishlistModel struct {
+	DB *sql.DB
+}
+
+func (m WishlistModel) Insert(wishlist *Wishlist) error {
+	query := `
+		INSERT INTO wishlists (user_id, name)
+		VALUES ($1, $2)
+		RETURNING id, created_at, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, wishlist.UserID, wishlist.Name).Scan(&wishlist.ID, &wishlist.CreatedAt, &wishlist.Version)
+	if err != nil {
+		return translateError(err)
+	}
+	return nil
+}
+
+// GetAllForUser returns the user's wishlists, favourites first and the rest
+// by name, creating the favourites list if the user doesn't have it yet.
+func (m WishlistModel) GetAllForUser(userID int64) ([]*Wishlist, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.ensureFavourites(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT w.id, w.user_id, w.created_at, w.name, w.is_default, w.version,
+			(SELECT count(*) FROM wishlist_items AS i JOIN mhelmets ON mhelmets.id = i.helmet_id
+				WHERE i.wishlist_id = w.id AND mhelmets.deleted_at IS NULL AND mhelmets.status = 'published')
+		FROM wishlists AS w
+		WHERE w.user_id = $1
+		ORDER BY w.is_default DESC, LOWER(w.name), w.id`
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	wishlists := []*Wishlist{}
+
+	for rows.Next() {
+		var wishlist Wishlist
+		err := rows.Scan(
+			&wishlist.ID,
+			&wishlist.UserID,
+			&wishlist.CreatedAt,
+			&wishlist.Name,
+			&wishlist.Default,
+			&wishlist.Version,
+			&wishlist.ItemCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		wishlists = append(wishlists, &wishlist)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return wishlists, nil
+}
+
+// Get returns one of the user's wishlists. An id of 0 stands for the user's
+// favourites, which are created if they don't exist yet.
+func (m WishlistModel) Get(userID, id int64) (*Wishlist, error) {
+	if id < 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	if id == 0 {
+		err := m.ensureFavourites(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	query := `
+		SELECT w.id, w.user_id, w.created_at, w.name, w.is_default, w.version,
+			(SELECT count(*) FROM wishlist_items AS i JOIN mhelmets ON mhelmets.id = i.helmet_id
+				WHERE i.wishlist_id = w.id AND mhelmets.deleted_at IS NULL AND mhelmets.status = 'published')
+		FROM wishlists AS w
+		WHERE w.user_id = $1 AND (w.id = $2 OR ($2 = 0 AND w.is_default))`
+
+	var wishlist Wishlist
+
+	err := m.DB.QueryRowContext(ctx, query, userID, id).Scan(
+		&wishlist.ID,
+		&wishlist.UserID,
+		&wishlist.CreatedAt,
+		&wishlist.Name,
+		&wishlist.Default,
+		&wishlist.Version,
+		&wishlist.ItemCount,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &wishlist, nil
+}
+
+func (m WishlistModel) ensureFavourites(ctx context.Context, userID int64) error {
+	query := `
+		INSERT INTO wishlists (user_id, name, is_default)
+		VALUES ($1, $2, true)
+		ON CONFLICT (user_id) WHERE is_default DO NOTHING`
+
+	_, err := m.DB.ExecContext(ctx, query, userID, FavouritesName)
+	return err
+}
+
+func (m WishlistModel) Update(wishlist *Wishlist) error {
+	query := `
+		UPDATE wishlists
+		SET name = $1, version = version + 1
+		WHERE id = $2 AND user_id = $3 AND version = $4
+		RETURNING version`
+
+	args := []interface{}{wishlist.Name, wishlist.ID, wishlist.UserID, wishlist.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&wishlist.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return translateError(err)
+		}
+	}
+	return nil
+}
+
+func (m WishlistModel) Delete(userID, id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM wishlists
+		WHERE id = $1 AND user_id = $2 AND NOT is_default`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// AddHelmet puts a helmet on a wishlist. Adding a helmet that is already on
+// the list does nothing.
+func (m WishlistModel) AddHelmet(wishlistID, helmetID int64) error {
+	query := `
+		INSERT INTO wishlist_items (wishlist_id, helmet_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, wishlistID, helmetID)
+	return translateError(err)
+}
+
+func (m WishlistModel) RemoveHelmet(wishlistID, helmetID int64) error {
+	query := `
+		DELETE FROM wishlist_items
+		WHERE wishlist_id = $1 AND helmet_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, wishlistID, helmetID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m WishlistModel) GetItems(wishlistID int64, filters Filters) ([]*WishlistItem, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), i.added_at, mhelmets.id, mhelmets.created_at, mhelmets.name, mhelmets.year, mhelmets.material,
+			mhelmets.ventilation, mhelmets.protection, mhelmets.weight, mhelmets.sun_protection,
+			COALESCE(mhelmets.manufacturer_id, 0), mhelmets.certifications, mhelmets.sharp_rating, mhelmets.status, mhelmets.version
+		FROM wishlist_items AS i
+		JOIN mhelmets ON mhelmets.id = i.helmet_id
+		WHERE i.wishlist_id = $1 AND mhelmets.deleted_at IS NULL AND mhelmets.status = 'published'
+		ORDER BY i.%s %s, i.helmet_id ASC
+		LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, wishlistID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	items := []*WishlistItem{}
+	helmets := []*Helmet{}
+
+	for rows.Next() {
+		var item WishlistItem
+		var helmet Helmet
+		var standards []string
+		var sharpRating sql.NullInt16
+		err := rows.Scan(
+			&totalRecords,
+			&item.AddedAt,
+			&helmet.ID,
+			&helmet.CreatedAt,
+			&helmet.Name,
+			&helmet.Year,
+			&helmet.Material,
+			&helmet.Ventilation,
+			&helmet.Protection,
+			&helmet.Weight,
+			&helmet.SunProtection,
+			&helmet.ManufacturerID,
+			pq.Array(&standards),
+			&sharpRating,
+			&helmet.Status,
+			&helmet.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		helmet.Certifications = makeCertifications(standards, sharpRating)
+		item.HelmetID = helmet.ID
+		item.Helmet = &helmet
+		items = append(items, &item)
+		helmets = append(helmets, &helmet)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	if err = embedHelmets(ctx, m.DB, helmets...); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return items, metadata, nil
+}
+
+// Favourited reports which of the helmets are on the user's favourites.
+func (m WishlistModel) Favourited(userID int64, helmetIDs []int64) (map[int64]bool, error) {
+	query := `
+		SELECT i.helmet_id
+		FROM wishlist_items AS i
+		JOIN wishlists AS w ON w.id = i.wishlist_id
+		WHERE w.user_id = $1 AND w.is_default AND i.helmet_id = ANY($2)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, pq.Array(helmetIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	favourited := make(map[int64]bool)
+
+	for rows.Next() {
+		var helmetID int64
+		if err := rows.Scan(&helmetID); err != nil {
+			return nil, err
+		}
+		favourited[helmetID] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return favourited, nil
+}
@@ -0,0 +1,223 @@
+package data
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+type MemoryWishlistModel struct {
+	store *memoryStore
+}
+
+func (m MemoryWishlistModel) Insert(wishlist *Wishlist) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	return m.store.insertWishlist(wishlist)
+}
+
+func (m MemoryWishlistModel) GetAllForUser(userID int64) ([]*Wishlist, error) {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if err := m.store.ensureFavourites(userID); err != nil {
+		return nil, err
+	}
+
+	wishlists := []*Wishlist{}
+	for _, wishlist := range m.store.wishlists {
+		if wishlist.UserID != userID {
+			continue
+		}
+		wishlist := wishlist
+		wishlist.ItemCount = int32(len(m.store.liveWishlistItems(wishlist.ID)))
+		wishlists = append(wishlists, &wishlist)
+	}
+
+	sort.Slice(wishlists, func(i, j int) bool {
+		a, b := wishlists[i], wishlists[j]
+		if a.Default != b.Default {
+			return a.Default
+		}
+		if c := strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)); c != 0 {
+			return c < 0
+		}
+		return a.ID < b.ID
+	})
+	return wishlists, nil
+}
+
+func (m MemoryWishlistModel) Get(userID, id int64) (*Wishlist, error) {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if id == 0 {
+		if err := m.store.ensureFavourites(userID); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, wishlist := range m.store.wishlists {
+		if wishlist.UserID == userID && (wishlist.ID == id || (id == 0 && wishlist.Default)) {
+			wishlist.ItemCount = int32(len(m.store.liveWishlistItems(wishlist.ID)))
+			return &wishlist, nil
+		}
+	}
+	return nil, ErrRecordNotFound
+}
+
+func (m MemoryWishlistModel) Update(wishlist *Wishlist) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	current, ok := m.store.wishlists[wishlist.ID]
+	if !ok || current.UserID != wishlist.UserID || current.Version != wishlist.Version {
+		return ErrEditConflict
+	}
+	if m.store.wishlistNameTaken(wishlist) {
+		return uniqueViolation("wishlists_user_id_name_idx")
+	}
+
+	wishlist.Version++
+	m.store.wishlists[wishlist.ID] = *wishlist
+	return nil
+}
+
+func (m MemoryWishlistModel) Delete(userID, id int64) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	wishlist, ok := m.store.wishlists[id]
+	if !ok || wishlist.UserID != userID || wishlist.Default {
+		return ErrRecordNotFound
+	}
+	delete(m.store.wishlists, id)
+	delete(m.store.wishlistItems, id)
+	return nil
+}
+
+func (m MemoryWishlistModel) AddHelmet(wishlistID, helmetID int64) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if _, ok := m.store.wishlists[wishlistID]; !ok {
+		return foreignKeyViolation("wishlist_items", "wishlist_items_wishlist_id_fkey")
+	}
+	if _, ok := m.store.helmets[helmetID]; !ok {
+		return foreignKeyViolation("wishlist_items", "wishlist_items_helmet_id_fkey")
+	}
+
+	items := m.store.wishlistItems[wishlistID]
+	if items == nil {
+		items = make(map[int64]time.Time)
+		m.store.wishlistItems[wishlistID] = items
+	}
+	if _, ok := items[helmetID]; !ok {
+		items[helmetID] = memoryNow()
+	}
+	return nil
+}
+
+func (m MemoryWishlistModel) RemoveHelmet(wishlistID, helmetID int64) error {
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	if _, ok := m.store.wishlistItems[wishlistID][helmetID]; !ok {
+		return ErrRecordNotFound
+	}
+	delete(m.store.wishlistItems[wishlistID], helmetID)
+	return nil
+}
+
+func (m MemoryWishlistModel) GetItems(wishlistID int64, filters Filters) ([]*WishlistItem, Metadata, error) {
+	m.store.mu.RLock()
+	items := m.store.liveWishlistItems(wishlistID)
+	for _, item := range items {
+		helmet := m.store.helmets[item.HelmetID]
+		m.store.embedHelmet(&helmet)
+		item.Helmet = &helmet
+	}
+	m.store.mu.RUnlock()
+
+	// Items can only be sorted by added_at.
+	descending := filters.sortDirection() == "DESC"
+	sort.Slice(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if !a.AddedAt.Equal(b.AddedAt) {
+			return a.AddedAt.Before(b.AddedAt) != descending
+		}
+		return a.HelmetID < b.HelmetID
+	})
+
+	start, end := pageBounds(len(items), filters)
+	return items[start:end], calculateMetadata(len(items), filters.Page, filters.PageSize), nil
+}
+
+func (m MemoryWishlistModel) Favourited(userID int64, helmetIDs []int64) (map[int64]bool, error) {
+	m.store.mu.RLock()
+	defer m.store.mu.RUnlock()
+
+	favourited := make(map[int64]bool)
+	for _, wishlist := range m.store.wishlists {
+		if wishlist.UserID != userID || !wishlist.Default {
+			continue
+		}
+		for _, id := range helmetIDs {
+			if _, ok := m.store.wishlistItems[wishlist.ID][id]; ok {
+				favourited[id] = true
+			}
+		}
+	}
+	return favourited, nil
+}
+
+// insertWishlist saves a new wishlist. The caller must hold the store lock.
+func (s *memoryStore) insertWishlist(wishlist *Wishlist) error {
+	if s.wishlistNameTaken(wishlist) {
+		return uniqueViolation("wishlists_user_id_name_idx")
+	}
+
+	s.wishlistsSeq++
+	wishlist.ID = s.wishlistsSeq
+	wishlist.CreatedAt = memoryNow()
+	wishlist.Version = 1
+	s.wishlists[wishlist.ID] = *wishlist
+	return nil
+}
+
+// ensureFavourites creates the user's favourites list if they don't have one
+// yet. The caller must hold the write lock.
+func (s *memoryStore) ensureFavourites(userID int64) error {
+	for _, wishlist := range s.wishlists {
+		if wishlist.UserID == userID && wishlist.Default {
+			return nil
+		}
+	}
+	return s.insertWishlist(&Wishlist{UserID: userID, Name: FavouritesName, Default: true})
+}
+
+// wishlistNameTaken reports whether another of the user's wishlists has the
+// same name, ignoring case like the wishlists_user_id_name_idx index. The
+// caller must hold the store lock.
+func (s *memoryStore) wishlistNameTaken(wishlist *Wishlist) bool {
+	for _, other := range s.wishlists {
+		if other.ID != wishlist.ID && other.UserID == wishlist.UserID && strings.EqualFold(other.Name, wishlist.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// liveWishlistItems returns the items of a wishlist whose helmets are
+// published and not in the trash, without their helmets. The caller must hold the store lock.
+func (s *memoryStore) liveWishlistItems(wishlistID int64) []*WishlistItem {
+	items := []*WishlistItem{}
+	for helmetID, addedAt := range s.wishlistItems[wishlistID] {
+		if helmet, ok := s.helmets[helmetID]; !ok || helmet.DeletedAt != nil || helmet.Status != StatusPublished {
+			continue
+		}
+		items = append(items, &WishlistItem{HelmetID: helmetID, AddedAt: addedAt})
+	}
+	return items
+}
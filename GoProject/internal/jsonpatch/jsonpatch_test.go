@@ -0,0 +1,347 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// The cases below marked with a section are the examples in appendix A of
+// RFC 7396 and RFC 6902.
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{"A.1 replace member", `{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"A.2 add member", `{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{"A.3 remove member", `{"a":"b"}`, `{"a":null}`, `{}`},
+		{"A.4 remove one of two members", `{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{"A.5 replace array with string", `{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"A.6 replace string with array", `{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{"A.7 nested object", `{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{"A.8 arrays are replaced", `{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{"A.9 array document", `["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{"A.10 array patch", `{"a":"b"}`, `["c"]`, `["c"]`},
+		{"A.11 null patch", `{"a":"foo"}`, `null`, `null`},
+		{"A.12 string patch", `{"a":"foo"}`, `"bar"`, `"bar"`},
+		{"A.13 null in document is kept", `{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{"A.14 object patch of array", `[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{"A.15 nested nulls are removed", `{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		{"Empty patch", `{"a":"b"}`, `{}`, `{"a":"b"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertJSONEqual(t, got, tt.want)
+		})
+	}
+}
+
+func TestMergePatchInvalid(t *testing.T) {
+	_, err := MergePatch([]byte(`{"a":"b"}`), []byte(`{"a":`))
+	if !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("got error %v, want ErrInvalidPatch", err)
+	}
+}
+
+func TestPatchApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		patch    string
+		want     string
+		wantErr  bool
+		wantTest bool
+	}{
+		{
+			name:  "A.1 add object member",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/baz","value":"qux"}]`,
+			want:  `{"baz":"qux","foo":"bar"}`,
+		},
+		{
+			name:  "A.2 add array element",
+			doc:   `{"foo":["bar","baz"]}`,
+			patch: `[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			want:  `{"foo":["bar","qux","baz"]}`,
+		},
+		{
+			name:  "A.3 remove object member",
+			doc:   `{"baz":"qux","foo":"bar"}`,
+			patch: `[{"op":"remove","path":"/baz"}]`,
+			want:  `{"foo":"bar"}`,
+		},
+		{
+			name:  "A.4 remove array element",
+			doc:   `{"foo":["bar","qux","baz"]}`,
+			patch: `[{"op":"remove","path":"/foo/1"}]`,
+			want:  `{"foo":["bar","baz"]}`,
+		},
+		{
+			name:  "A.5 replace value",
+			doc:   `{"baz":"qux","foo":"bar"}`,
+			patch: `[{"op":"replace","path":"/baz","value":"boo"}]`,
+			want:  `{"baz":"boo","foo":"bar"}`,
+		},
+		{
+			name:  "A.6 move value",
+			doc:   `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			patch: `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			want:  `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`,
+		},
+		{
+			name:  "A.7 move array element",
+			doc:   `{"foo":["all","grass","cows","eat"]}`,
+			patch: `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			want:  `{"foo":["all","cows","eat","grass"]}`,
+		},
+		{
+			name:  "A.8 test success",
+			doc:   `{"baz":"qux","foo":["a",2,"c"]}`,
+			patch: `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			want:  `{"baz":"qux","foo":["a",2,"c"]}`,
+		},
+		{
+			name:     "A.9 test failure",
+			doc:      `{"baz":"qux"}`,
+			patch:    `[{"op":"test","path":"/baz","value":"bar"}]`,
+			wantErr:  true,
+			wantTest: true,
+		},
+		{
+			name:  "A.10 add nested member object",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`,
+			want:  `{"foo":"bar","child":{"grandchild":{}}}`,
+		},
+		{
+			name:  "A.11 unrecognized elements are ignored",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/baz","value":"qux","xyz":123}]`,
+			want:  `{"foo":"bar","baz":"qux"}`,
+		},
+		{
+			name:    "A.12 add to nonexistent target",
+			doc:     `{"foo":"bar"}`,
+			patch:   `[{"op":"add","path":"/baz/bat","value":"qux"}]`,
+			wantErr: true,
+		},
+		{
+			name:  "A.14 escape ordering",
+			doc:   `{"/":9,"~1":10}`,
+			patch: `[{"op":"test","path":"/~01","value":10}]`,
+			want:  `{"/":9,"~1":10}`,
+		},
+		{
+			name:     "A.15 strings and numbers differ",
+			doc:      `{"/":9,"~1":10}`,
+			patch:    `[{"op":"test","path":"/~01","value":"10"}]`,
+			wantErr:  true,
+			wantTest: true,
+		},
+		{
+			name:  "A.16 add array value",
+			doc:   `{"foo":["bar"]}`,
+			patch: `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`,
+			want:  `{"foo":["bar",["abc","def"]]}`,
+		},
+		{
+			name:  "Escaped slash",
+			doc:   `{"a/b":1}`,
+			patch: `[{"op":"replace","path":"/a~1b","value":2}]`,
+			want:  `{"a/b":2}`,
+		},
+		{
+			name:  "Add replaces existing member",
+			doc:   `{"a":1}`,
+			patch: `[{"op":"add","path":"/a","value":2}]`,
+			want:  `{"a":2}`,
+		},
+		{
+			name:  "Add at end of array by index",
+			doc:   `{"a":[1,2]}`,
+			patch: `[{"op":"add","path":"/a/2","value":3}]`,
+			want:  `{"a":[1,2,3]}`,
+		},
+		{
+			name:    "Add past end of array",
+			doc:     `{"a":[1,2]}`,
+			patch:   `[{"op":"add","path":"/a/3","value":3}]`,
+			wantErr: true,
+		},
+		{
+			name:    "Leading zero index",
+			doc:     `{"a":[1,2]}`,
+			patch:   `[{"op":"replace","path":"/a/01","value":3}]`,
+			wantErr: true,
+		},
+		{
+			name:    "Remove end of array",
+			doc:     `{"a":[1,2]}`,
+			patch:   `[{"op":"remove","path":"/a/-"}]`,
+			wantErr: true,
+		},
+		{
+			name:    "Replace missing member",
+			doc:     `{"a":1}`,
+			patch:   `[{"op":"replace","path":"/b","value":2}]`,
+			wantErr: true,
+		},
+		{
+			name:  "Replace whole document",
+			doc:   `{"a":1}`,
+			patch: `[{"op":"replace","path":"","value":[1]}]`,
+			want:  `[1]`,
+		},
+		{
+			name:    "Remove whole document",
+			doc:     `{"a":1}`,
+			patch:   `[{"op":"remove","path":""}]`,
+			wantErr: true,
+		},
+		{
+			name:    "Move into own child",
+			doc:     `{"a":{"b":{}}}`,
+			patch:   `[{"op":"move","from":"/a","path":"/a/b/c"}]`,
+			wantErr: true,
+		},
+		{
+			name:  "Move onto itself",
+			doc:   `{"a":1}`,
+			patch: `[{"op":"move","from":"/a","path":"/a"}]`,
+			want:  `{"a":1}`,
+		},
+		{
+			name:  "Copy is deep",
+			doc:   `{"a":{"b":1}}`,
+			patch: `[{"op":"copy","from":"/a","path":"/c"},{"op":"add","path":"/c/b","value":2}]`,
+			want:  `{"a":{"b":1},"c":{"b":2}}`,
+		},
+		{
+			name:  "Add null",
+			doc:   `{"a":1}`,
+			patch: `[{"op":"add","path":"/b","value":null}]`,
+			want:  `{"a":1,"b":null}`,
+		},
+		{
+			name:  "Test null",
+			doc:   `{"a":null}`,
+			patch: `[{"op":"test","path":"/a","value":null}]`,
+			want:  `{"a":null}`,
+		},
+		{
+			name:     "Test null against a value",
+			doc:      `{"a":1}`,
+			patch:    `[{"op":"test","path":"/a","value":null}]`,
+			wantErr:  true,
+			wantTest: true,
+		},
+		{
+			name:     "Test null against a missing member",
+			doc:      `{}`,
+			patch:    `[{"op":"test","path":"/a","value":null}]`,
+			wantErr:  true,
+			wantTest: true,
+		},
+		{
+			name:  "Test compares objects by value",
+			doc:   `{"a":{"x":1,"y":[1.0,"z"]}}`,
+			patch: `[{"op":"test","path":"/a","value":{"y":[1,"z"],"x":1}}]`,
+			want:  `{"a":{"x":1,"y":[1.0,"z"]}}`,
+		},
+		{
+			name:    "Path into scalar",
+			doc:     `{"a":1}`,
+			patch:   `[{"op":"add","path":"/a/b","value":2}]`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := DecodePatch([]byte(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := patch.Apply([]byte(tt.doc))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %s, want an error", got)
+				}
+				if errors.Is(err, ErrTestFailed) != tt.wantTest {
+					t.Errorf("got error %v, want ErrTestFailed to be wrapped: %t", err, tt.wantTest)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertJSONEqual(t, got, tt.want)
+		})
+	}
+}
+
+func TestDecodePatchInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+	}{
+		{"Not an array", `{"op":"add","path":"/a","value":1}`},
+		{"Malformed JSON", `[{"op":"add"`},
+		{"Unknown op", `[{"op":"merge","path":"/a","value":1}]`},
+		{"Missing op", `[{"path":"/a","value":1}]`},
+		{"Add without value", `[{"op":"add","path":"/a"}]`},
+		{"Replace without value", `[{"op":"replace","path":"/a"}]`},
+		{"Test without value", `[{"op":"test","path":"/a"}]`},
+		{"Relative path", `[{"op":"remove","path":"a"}]`},
+		{"Relative from", `[{"op":"move","from":"a","path":"/b"}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodePatch([]byte(tt.patch))
+			if !errors.Is(err, ErrInvalidPatch) {
+				t.Errorf("got error %v, want ErrInvalidPatch", err)
+			}
+		})
+	}
+}
+
+func TestPatchApplyLeavesDocumentOnFailure(t *testing.T) {
+	doc := []byte(`{"a":1}`)
+
+	patch, err := DecodePatch([]byte(`[{"op":"replace","path":"/a","value":2},{"op":"remove","path":"/b"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := patch.Apply(doc); err == nil {
+		t.Fatal("got no error")
+	}
+	assertJSONEqual(t, doc, `{"a":1}`)
+}
+
+func assertJSONEqual(t *testing.T, got []byte, want string) {
+	t.Helper()
+
+	var g, w interface{}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("decoding %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("decoding %s: %v", want, err)
+	}
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
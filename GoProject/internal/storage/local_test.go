@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalGet(t *testing.T) {
+	s, err := NewLocal(t.TempDir(), "http://localhost:4000/v1/images")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Put("mhelmets/1/front.jpg", strings.NewReader("jpeg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		key     string
+		want    string
+		wantErr error
+	}{
+		{"File", "mhelmets/1/front.jpg", "jpeg", nil},
+		{"Missing file", "mhelmets/1/back.jpg", "", ErrNotFound},
+		{"Directory", "mhelmets/1", "", ErrNotFound},
+		{"Top directory", "mhelmets", "", ErrNotFound},
+		{"Empty key", "", "", ErrNotFound},
+		{"Escaping key", "../front.jpg", "", ErrNotFound},
+		{"Hidden file", "mhelmets/1/.upload-1", "", ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := s.Get(tt.key)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			got, err := io.ReadAll(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
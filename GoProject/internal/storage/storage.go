@@ -0,0 +1,18 @@
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("storage: file not found")
+
+// Storage is where uploaded files are kept. Files are addressed by slash
+// separated keys such as "mhelmets/12/5d2f0c.jpg", and URL tells clients
+// where a file can be downloaded from.
+type Storage interface {
+	Put(key string, r io.Reader) error
+	Get(key string) (io.ReadCloser, error)
+	Delete(key string) error
+	URL(key string) string
+}
@@ -0,0 +1,91 @@
+package thumbnail
+
+import (
+	"image"
+	"image/draw"
+	"sort"
+)
+
+// Sizes are the thumbnails generated for every uploaded image, as the length
+// of their longest side in pixels.
+var Sizes = map[string]int{
+	"small":  160,
+	"medium": 480,
+	"large":  1024,
+}
+
+// Names returns the thumbnail size names from smallest to largest.
+func Names() []string {
+	names := make([]string, 0, len(Sizes))
+	for name := range Sizes {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return Sizes[names[i]] < Sizes[names[j]]
+	})
+	return names
+}
+
+// Generate scales src down so that its longest side is at most maxSide
+// pixels, keeping its aspect ratio. Every destination pixel is the average
+// of the source pixels it covers, which is slower than nearest neighbour
+// sampling but doesn't alias. Images that are already small enough are
+// copied as they are, never scaled up.
+func Generate(src image.Image, maxSide int) *image.RGBA {
+	rgba := toRGBA(src)
+	sw, sh := rgba.Rect.Dx(), rgba.Rect.Dy()
+
+	dw, dh := sw, sh
+	if sw > maxSide || sh > maxSide {
+		if sw >= sh {
+			dw, dh = maxSide, max(1, sh*maxSide/sw)
+		} else {
+			dw, dh = max(1, sw*maxSide/sh), maxSide
+		}
+	}
+	if dw == sw && dh == sh {
+		return rgba
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*sh/dh, max((y+1)*sh/dh, y*sh/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*sw/dw, max((x+1)*sw/dw, x*sw/dw+1)
+
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += int(p[0])
+					g += int(p[1])
+					b += int(p[2])
+					a += int(p[3])
+					n++
+				}
+			}
+
+			p := dst.Pix[y*dst.Stride+x*4 : y*dst.Stride+x*4+4]
+			p[0], p[1], p[2], p[3] = uint8(r/n), uint8(g/n), uint8(b/n), uint8(a/n)
+		}
+	}
+	return dst
+}
+
+func toRGBA(src image.Image) *image.RGBA {
+	if rgba, ok := src.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+	bounds := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Rect, src, bounds.Min, draw.Src)
+	return rgba
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,3 @@
+DELETE FROM permissions WHERE code = 'mhelmets:purge';
+DROP INDEX IF EXISTS mhelmets_deleted_at_idx;
+ALTER TABLE mhelmets DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,6 @@
+ALTER TABLE mhelmets ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone;
+CREATE INDEX IF NOT EXISTS mhelmets_deleted_at_idx ON mhelmets (deleted_at) WHERE deleted_at IS NOT NULL;
+
+INSERT INTO permissions (code)
+VALUES
+    ('mhelmets:purge');
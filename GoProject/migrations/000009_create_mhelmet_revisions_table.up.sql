@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS mhelmet_revisions (
+    id bigserial PRIMARY KEY,
+    helmet_id bigint NOT NULL REFERENCES mhelmets ON DELETE CASCADE,
+    version integer NOT NULL,
+    action text NOT NULL,
+    user_id bigint REFERENCES users ON DELETE SET NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    before jsonb NOT NULL,
+    after jsonb NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS mhelmet_revisions_helmet_id_idx ON mhelmet_revisions (helmet_id, id);
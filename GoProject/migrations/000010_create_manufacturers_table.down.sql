@@ -0,0 +1,4 @@
+DELETE FROM permissions WHERE code IN ('manufacturers:read', 'manufacturers:write');
+DROP INDEX IF EXISTS mhelmets_manufacturer_id_idx;
+ALTER TABLE mhelmets DROP COLUMN IF EXISTS manufacturer_id;
+DROP TABLE IF EXISTS manufacturers;
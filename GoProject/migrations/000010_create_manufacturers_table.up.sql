@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS manufacturers (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name text NOT NULL,
+    country text NOT NULL DEFAULT '',
+    website text NOT NULL DEFAULT '',
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS manufacturers_name_idx ON manufacturers (LOWER(name));
+
+ALTER TABLE mhelmets ADD COLUMN IF NOT EXISTS manufacturer_id bigint REFERENCES manufacturers ON DELETE RESTRICT;
+CREATE INDEX IF NOT EXISTS mhelmets_manufacturer_id_idx ON mhelmets (manufacturer_id);
+
+INSERT INTO permissions (code)
+VALUES
+    ('manufacturers:read'),
+    ('manufacturers:write');
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS mhelmet_variants (
+    id bigserial PRIMARY KEY,
+    helmet_id bigint NOT NULL REFERENCES mhelmets ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    size text NOT NULL,
+    colour text NOT NULL DEFAULT '',
+    sku text NOT NULL,
+    weight float,
+    stock integer NOT NULL DEFAULT 0,
+    version integer NOT NULL DEFAULT 1,
+    CONSTRAINT mhelmet_variants_stock_check CHECK (stock >= 0),
+    CONSTRAINT mhelmet_variants_weight_check CHECK (weight BETWEEN 0.5 AND 2.5)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS mhelmet_variants_sku_idx ON mhelmet_variants (sku);
+CREATE UNIQUE INDEX IF NOT EXISTS mhelmet_variants_helmet_id_size_colour_idx ON mhelmet_variants (helmet_id, size, LOWER(colour));
+CREATE INDEX IF NOT EXISTS mhelmet_variants_size_in_stock_idx ON mhelmet_variants (size, helmet_id) WHERE stock > 0;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS mhelmet_price_changes;
+DROP TABLE IF EXISTS mhelmet_prices;
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS mhelmet_prices (
+    id bigserial PRIMARY KEY,
+    helmet_id bigint NOT NULL REFERENCES mhelmets ON DELETE CASCADE,
+    currency char(3) NOT NULL,
+    amount bigint NOT NULL,
+    effective_from timestamp(0) with time zone NOT NULL,
+    CONSTRAINT mhelmet_prices_amount_check CHECK (amount > 0),
+    CONSTRAINT mhelmet_prices_helmet_id_currency_effective_from_key UNIQUE (helmet_id, currency, effective_from)
+);
+
+CREATE TABLE IF NOT EXISTS mhelmet_price_changes (
+    id bigserial PRIMARY KEY,
+    helmet_id bigint NOT NULL REFERENCES mhelmets ON DELETE CASCADE,
+    currency char(3) NOT NULL,
+    effective_from timestamp(0) with time zone NOT NULL,
+    old_amount bigint,
+    new_amount bigint,
+    user_id bigint REFERENCES users ON DELETE SET NULL,
+    changed_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS mhelmet_price_changes_helmet_id_idx ON mhelmet_price_changes (helmet_id, id);
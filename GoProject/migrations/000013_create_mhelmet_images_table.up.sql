@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS mhelmet_images (
+    id bigserial PRIMARY KEY,
+    helmet_id bigint NOT NULL REFERENCES mhelmets ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    position integer NOT NULL,
+    content_type text NOT NULL,
+    size bigint NOT NULL,
+    width integer NOT NULL,
+    height integer NOT NULL,
+    url text NOT NULL,
+    thumbnails jsonb NOT NULL DEFAULT '{}',
+    keys text[] NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS mhelmet_images_helmet_id_position_idx ON mhelmet_images (helmet_id, position);
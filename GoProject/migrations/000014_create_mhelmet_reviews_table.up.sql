@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS mhelmet_reviews (
+    id bigserial PRIMARY KEY,
+    helmet_id bigint NOT NULL REFERENCES mhelmets ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    rating smallint NOT NULL,
+    title text NOT NULL,
+    body text NOT NULL DEFAULT '',
+    version integer NOT NULL DEFAULT 1,
+    CONSTRAINT mhelmet_reviews_rating_check CHECK (rating BETWEEN 1 AND 5)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS mhelmet_reviews_helmet_id_user_id_idx ON mhelmet_reviews (helmet_id, user_id);
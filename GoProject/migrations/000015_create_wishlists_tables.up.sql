@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS wishlists (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name text NOT NULL,
+    is_default boolean NOT NULL DEFAULT false,
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS wishlists_user_id_name_idx ON wishlists (user_id, LOWER(name));
+CREATE UNIQUE INDEX IF NOT EXISTS wishlists_user_id_default_idx ON wishlists (user_id) WHERE is_default;
+
+CREATE TABLE IF NOT EXISTS wishlist_items (
+    wishlist_id bigint NOT NULL REFERENCES wishlists ON DELETE CASCADE,
+    helmet_id bigint NOT NULL REFERENCES mhelmets ON DELETE CASCADE,
+    added_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (wishlist_id, helmet_id)
+);
+
+CREATE INDEX IF NOT EXISTS wishlist_items_helmet_id_idx ON wishlist_items (helmet_id);
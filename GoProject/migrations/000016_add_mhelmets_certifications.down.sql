@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS mhelmets_certifications_idx;
+ALTER TABLE mhelmets DROP COLUMN IF EXISTS sharp_rating;
+ALTER TABLE mhelmets DROP COLUMN IF EXISTS certifications;
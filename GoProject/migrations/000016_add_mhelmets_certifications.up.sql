@@ -0,0 +1,19 @@
+ALTER TABLE mhelmets ADD COLUMN IF NOT EXISTS certifications text[] NOT NULL DEFAULT '{}';
+ALTER TABLE mhelmets ADD COLUMN IF NOT EXISTS sharp_rating smallint;
+ALTER TABLE mhelmets ADD CONSTRAINT mhelmets_sharp_rating_check CHECK (sharp_rating BETWEEN 1 AND 5);
+
+-- Existing helmets get the certifications their protection text names, as
+-- far as the standards existed in their release year.
+UPDATE mhelmets SET certifications = array_remove(ARRAY[
+    CASE WHEN protection ILIKE '%DOT%' THEN 'dot-fmvss-218' END,
+    CASE
+        WHEN protection ILIKE '%22.06%' AND year >= 2020 THEN 'ece-22.06'
+        WHEN protection ILIKE '%ECE%' AND year >= 2000 THEN 'ece-22.05'
+    END,
+    CASE
+        WHEN protection ILIKE '%Snell%' AND year >= 2019 THEN 'snell-m2020'
+        WHEN protection ILIKE '%Snell%' AND year >= 2015 THEN 'snell-m2015'
+    END
+], NULL);
+
+CREATE INDEX IF NOT EXISTS mhelmets_certifications_idx ON mhelmets USING GIN (certifications);
@@ -0,0 +1,8 @@
+DELETE FROM permissions WHERE code = 'mhelmets:publish';
+DROP INDEX IF EXISTS mhelmets_unpublish_at_idx;
+DROP INDEX IF EXISTS mhelmets_publish_at_idx;
+DROP INDEX IF EXISTS mhelmets_status_idx;
+ALTER TABLE mhelmet_revisions DROP COLUMN IF EXISTS status;
+ALTER TABLE mhelmets DROP COLUMN IF EXISTS unpublish_at;
+ALTER TABLE mhelmets DROP COLUMN IF EXISTS publish_at;
+ALTER TABLE mhelmets DROP COLUMN IF EXISTS status;
@@ -0,0 +1,20 @@
+-- Helmets added before the workflow existed were public, so they start out
+-- published. New helmets are drafts until they are published.
+ALTER TABLE mhelmets ADD COLUMN IF NOT EXISTS status text NOT NULL DEFAULT 'published';
+ALTER TABLE mhelmets ALTER COLUMN status SET DEFAULT 'draft';
+ALTER TABLE mhelmets ADD CONSTRAINT mhelmets_status_check CHECK (status IN ('draft', 'in_review', 'published', 'archived'));
+ALTER TABLE mhelmets ADD COLUMN IF NOT EXISTS publish_at timestamp(0) with time zone;
+ALTER TABLE mhelmets ADD COLUMN IF NOT EXISTS unpublish_at timestamp(0) with time zone;
+
+-- Revisions record the status a change left the helmet in, which was
+-- published for the changes made before the workflow existed.
+ALTER TABLE mhelmet_revisions ADD COLUMN IF NOT EXISTS status text NOT NULL DEFAULT 'published';
+ALTER TABLE mhelmet_revisions ALTER COLUMN status DROP DEFAULT;
+
+CREATE INDEX IF NOT EXISTS mhelmets_status_idx ON mhelmets (status);
+CREATE INDEX IF NOT EXISTS mhelmets_publish_at_idx ON mhelmets (publish_at) WHERE publish_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS mhelmets_unpublish_at_idx ON mhelmets (unpublish_at) WHERE unpublish_at IS NOT NULL;
+
+INSERT INTO permissions (code)
+VALUES
+    ('mhelmets:publish');
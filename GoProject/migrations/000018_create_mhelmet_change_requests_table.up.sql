@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS mhelmet_change_requests (
+    id bigserial PRIMARY KEY,
+    helmet_id bigint NOT NULL REFERENCES mhelmets ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    base_version integer NOT NULL,
+    patch jsonb NOT NULL,
+    comment text NOT NULL DEFAULT '',
+    status text NOT NULL DEFAULT 'pending',
+    reviewer_id bigint REFERENCES users ON DELETE SET NULL,
+    reviewed_at timestamp(0) with time zone,
+    review_comment text NOT NULL DEFAULT '',
+    version integer NOT NULL DEFAULT 1,
+    CONSTRAINT mhelmet_change_requests_status_check CHECK (status IN ('pending', 'approved', 'rejected'))
+);
+
+CREATE INDEX IF NOT EXISTS mhelmet_change_requests_helmet_id_idx ON mhelmet_change_requests (helmet_id, id);
+CREATE INDEX IF NOT EXISTS mhelmet_change_requests_user_id_idx ON mhelmet_change_requests (user_id, id);
+CREATE INDEX IF NOT EXISTS mhelmet_change_requests_pending_idx ON mhelmet_change_requests (id) WHERE status = 'pending';